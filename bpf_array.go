@@ -17,9 +17,8 @@ package ebpfstruct
 
 import (
 	"errors"
-	"log/slog"
+	"slices"
 	"sync"
-	"time"
 
 	"github.com/alexandremahdhaoui/ebpfstruct/internal/util"
 
@@ -27,14 +26,10 @@ import (
 	"github.com/cilium/ebpf"
 )
 
-const (
-	deferableSwitchoverMaxTries = 3
-	deferableSwitchoverTimeout  = 5 * time.Millisecond
-)
-
 var (
 	ErrEBPFObjectsMustNotBeNil = errors.New("ebpf objects must not be nil")
 	ErrCreatingNewArray        = errors.New("creating new array")
+	ErrSwitchoverPending       = errors.New("a deferred switchover is pending")
 )
 
 // -------------------------------------------------------------------
@@ -42,11 +37,36 @@ var (
 // -------------------------------------------------------------------
 
 // Wraps bpf objects with a convenient interface for testing.
+//
+// Notes:
+//   - Array is thread-safe.
+//   - While a deferred switchover is pending, i.e. SetAndDeferSwitchover
+//     or PrepareSwitchover returned but the switchover has not been
+//     performed or aborted yet, updates return ErrSwitchoverPending.
 type Array[T any] interface {
 	// Done returns a channel that's closed when work done on behalf of this
 	// interface has been gracefully terminated.
 	Done() <-chan struct{}
 
+	// Close releases the resources owned by the Array, e.g. the bpf objects
+	// loaded by NewArrayFromPinPath, and closes the channel returned by Done().
+	// Subsequent updates return ErrDataStructureClosed.
+	//
+	// Closing the doneCh passed to the constructor closes the Array.
+	// Close can be called multiple times.
+	Close() error
+
+	// Get returns the values of the ACTIVE map, i.e. the values currently
+	// seen by the bpf program.
+	//
+	// Indices in the interval [0, activeLen) are looked up in batch from the
+	// active map, unless the Array was created with the WithCache() option.
+	Get() ([]T, error)
+
+	// GetPassive returns the values of the PASSIVE map, e.g. values staged
+	// by SetAndDeferSwitchover that are not yet seen by the bpf program.
+	GetPassive() ([]T, error)
+
 	// -- Set all values of the BPF map to the one of the input map.
 	//	  - DELETE all entries in the *ebpf.Map that have index > newLen.
 	//	  - UPDATE_BATCH all entries with index in the interval [0, newLen].
@@ -62,17 +82,23 @@ type Array[T any] interface {
 	// structures from the bpf-program point of view, by sharing the same
 	// "activePointer" bpf variable with multiple BPF data structures.
 	//
-	// SetAndDeferSwitchover returns a function that can be called once to
+	// SetAndDeferSwitchover returns a commit function performing the
+	// switchover and an abort function discarding the staged data. They
+	// behave like Switchover.Commit() and Switchover.Abort(): commit
+	// retries if errors are encountered and returns an error if the
+	// switchover cannot be performed.
+	//
+	// Either commit or abort must be called, even if the same
+	// "activePointer" bpf variable is used for multiple data structures:
+	// they update internal variables in userspace, and updates return
+	// ErrSwitchoverPending until one of them is called.
+	SetAndDeferSwitchover(values []T) (commit func() error, abort func(), err error)
+
+	// PrepareSwitchover updates the passive internal map but does not
 	// perform the switchover.
-	// The returned function can only be called once.
-	// Please note that the returned function will retry if errors are
-	// encountered.
 	//
-	// The deferable switchover function must be called, even if the same
-	// "activePointer" bpf variable is used for multiple data structures.
-	// The deferable switchover function must be called because it updates
-	// internal variables in userspace.
-	SetAndDeferSwitchover(values []T) (func(), error)
+	// Either Switchover.Commit() or Switchover.Abort() must be called.
+	PrepareSwitchover(values []T) (Switchover, error)
 }
 
 // doneCh is a channel used to notify the bpf data structures or bpf
@@ -82,12 +108,33 @@ func NewArray[T any](
 	aLen, bLen *ebpf.Variable,
 	activePointer *ebpf.Variable,
 	doneCh <-chan struct{},
+	opts ...Option,
 ) (Array[T], error) {
 	if util.AnyPtrIsNil(a, b, aLen, bLen, activePointer) {
 		return nil, flaterrors.Join(ErrEBPFObjectsMustNotBeNil, ErrCreatingNewArray)
 	}
 
-	return &bpfArray[T]{
+	if err := validateControlVariables(aLen, bLen, activePointer); err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewArray)
+	}
+
+	return newArray[T](a, b, aLen, bLen, activePointer, doneCh, opts...)
+}
+
+func newArray[T any](
+	a, b *ebpf.Map,
+	aLen, bLen variable,
+	activePointer variable,
+	doneCh <-chan struct{},
+	opts ...Option,
+) (Array[T], error) {
+	if err := validateArrayMaps[T](a, b); err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewArray)
+	}
+
+	o := newOptions(opts...)
+
+	arr := &bpfArray[T]{
 		a:                  a,
 		b:                  b,
 		aLenCache:          0,
@@ -96,8 +143,22 @@ func NewArray[T any](
 		bLen:               bLen,
 		activePointer:      activePointer,
 		activePointerCache: 0,
-		doneCh:             doneCh,
-	}, nil
+		cache:              o.cache,
+		retryPolicy:        o.retryPolicy,
+		mu:                 &sync.RWMutex{},
+		switchoverPending:  false,
+		lc:                 nil,
+	}
+
+	if o.stateRecovery {
+		if err := arr.recoverState(); err != nil {
+			return nil, flaterrors.Join(err, ErrCreatingNewArray)
+		}
+	}
+
+	arr.lc = newLifecycle(doneCh, o.closer)
+
+	return arr, nil
 }
 
 type bpfArray[T any] struct {
@@ -120,7 +181,7 @@ type bpfArray[T any] struct {
 	a, b *ebpf.Map
 
 	// the bpf variable storing the length of the respective a or b map.
-	aLen, bLen *ebpf.Variable
+	aLen, bLen variable
 	// We save a few syscalls by caching `{a,b}len` instead of reading the bpf
 	// variable.
 	aLenCache, bLenCache uint32
@@ -128,22 +189,75 @@ type bpfArray[T any] struct {
 	// activePointer must be defined in the bpf program as __u8.
 	// - When set to 0, the "active map" is `a` & the "active length" is `aLen`.
 	// - When set to 1, the "active map" is `b` & the "active length" is `bLen`.
-	activePointer *ebpf.Variable
+	activePointer variable
 	// We save a few syscalls by caching `activePointer` value instead of reading
 	// the bpf variable.
 	activePointerCache uint8
 
-	// doneCh is a channel used to notify the bpf data structures or bpf
-	// program has been closed and they can no longer be used.
-	doneCh <-chan struct{}
+	// cache enables serving Get() & GetPassive() from {a,b}ValuesCache.
+	cache bool
+	// copies of the values written to the respective a or b map. Only
+	// populated when cache is enabled.
+	aValuesCache, bValuesCache []T
+
+	// retryPolicy configures how deferred switchovers are retried.
+	retryPolicy RetryPolicy
+
+	// mu protects all fields above from concurrent access. It is held for
+	// the whole duration of a set & switchover.
+	mu *sync.RWMutex
+	// switchoverPending is true while the function returned by
+	// SetAndDeferSwitchover has not been called.
+	switchoverPending bool
+
+	// lc ends when Close() is called or when the doneCh passed to the
+	// constructor is closed.
+	lc *lifecycle
 }
 
 func (arr *bpfArray[T]) Done() <-chan struct{} {
-	return arr.doneCh
+	return arr.lc.Done()
+}
+
+func (arr *bpfArray[T]) Close() error {
+	return arr.lc.Close()
+}
+
+// Get implements Array.
+func (arr *bpfArray[T]) Get() ([]T, error) {
+	arr.mu.RLock()
+	defer arr.mu.RUnlock()
+
+	if arr.activePointerCache == 0 {
+		return arr.get(arr.a, arr.aLenCache, arr.aValuesCache)
+	}
+	return arr.get(arr.b, arr.bLenCache, arr.bValuesCache)
+}
+
+// GetPassive implements Array.
+func (arr *bpfArray[T]) GetPassive() ([]T, error) {
+	arr.mu.RLock()
+	defer arr.mu.RUnlock()
+
+	if arr.activePointerCache == 0 {
+		return arr.get(arr.b, arr.bLenCache, arr.bValuesCache)
+	}
+	return arr.get(arr.a, arr.aLenCache, arr.aValuesCache)
 }
 
 // Set implements BPFMap.
 func (arr *bpfArray[T]) Set(values []T) error {
+	arr.mu.Lock()
+	defer arr.mu.Unlock()
+
+	if arr.lc.closed() {
+		return ErrDataStructureClosed
+	}
+
+	if arr.switchoverPending {
+		return ErrSwitchoverPending
+	}
+
 	if err := arr.set(values); err != nil {
 		return err
 	}
@@ -155,23 +269,34 @@ func (arr *bpfArray[T]) Set(values []T) error {
 	return nil
 }
 
-func (arr *bpfArray[T]) SetAndDeferSwitchover(values []T) (func(), error) {
+func (arr *bpfArray[T]) SetAndDeferSwitchover(values []T) (func() error, func(), error) {
+	sw, err := arr.PrepareSwitchover(values)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sw.Commit, sw.Abort, nil
+}
+
+func (arr *bpfArray[T]) PrepareSwitchover(values []T) (Switchover, error) {
+	arr.mu.Lock()
+	defer arr.mu.Unlock()
+
+	if arr.lc.closed() {
+		return nil, ErrDataStructureClosed
+	}
+
+	if arr.switchoverPending {
+		return nil, ErrSwitchoverPending
+	}
+
 	if err := arr.set(values); err != nil {
 		return nil, err
 	}
 
-	return sync.OnceFunc(func() {
-		var err error
-		for i := range deferableSwitchoverMaxTries {
-			if err = arr.switchover(); err != nil {
-				time.Sleep(time.Duration(i) * deferableSwitchoverTimeout)
-				continue
-			}
-			return
-		}
-		slog.Error("cannot perform differable switchover", "err", err.Error())
-		panic("CRITICAL ERROR")
-	}), nil
+	arr.switchoverPending = true
+
+	return newSwitchover(arr, arr.retryPolicy), nil
 }
 
 // set performs at most 2 syscalls
@@ -195,7 +320,9 @@ func (arr *bpfArray[T]) set(values []T) error {
 	newLen := uint32(len(values))
 
 	// -- DELETE all entries in the *ebpf.Map that have index > new_length.
-	if oldLen > newLen {
+	// Elements of array maps cannot be deleted: the length variable bounds
+	// what the bpf program reads.
+	if oldLen > newLen && !isArrayMapType(passiveMap.Type()) {
 		keys := make([]uint32, 0, oldLen-newLen) // TODO
 		for i := newLen; i < oldLen; i++ {
 			keys = append(keys, i)
@@ -211,6 +338,87 @@ func (arr *bpfArray[T]) set(values []T) error {
 		return err
 	}
 
+	if arr.cache {
+		arr.cachePassiveValues(slices.Clone(values))
+	}
+
+	return nil
+}
+
+// isArrayMapType returns true if elements of maps of type typ cannot be
+// deleted.
+func isArrayMapType(typ ebpf.MapType) bool {
+	return typ == ebpf.Array || typ == ebpf.PerCPUArray
+}
+
+// get returns the first length values of m. It performs no syscall if
+// cache is enabled.
+func (arr *bpfArray[T]) get(m *ebpf.Map, length uint32, valuesCache []T) ([]T, error) {
+	if arr.cache {
+		return slices.Clone(valuesCache), nil
+	}
+
+	return arr.lookup(m, length)
+}
+
+// lookup returns the first length values of m.
+func (arr *bpfArray[T]) lookup(m *ebpf.Map, length uint32) ([]T, error) {
+	keys := make([]uint32, length)
+	values := make([]T, length)
+	cursor := new(ebpf.MapBatchCursor)
+
+	// -- LOOKUP_BATCH
+	// The kernel may return fewer entries than requested, hence we loop
+	// until [0, length) has been read.
+	for n := 0; n < int(length); {
+		count, err := m.BatchLookup(cursor, keys[n:], values[n:], nil)
+		n += count
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			// the end of the map has been reached.
+			return values[:n], nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+// recoverState rebuilds the internal state from the kernel.
+func (arr *bpfArray[T]) recoverState() error {
+	activePointer, err := recoverActivePointer(arr.activePointer)
+	if err != nil {
+		return err
+	}
+
+	aLen, err := recoverLen(arr.aLen)
+	if err != nil {
+		return err
+	}
+
+	bLen, err := recoverLen(arr.bLen)
+	if err != nil {
+		return err
+	}
+
+	if aLen > arr.a.MaxEntries() || bLen > arr.b.MaxEntries() {
+		return flaterrors.Join(ErrInvalidRecoveredState, ErrRecoveringState)
+	}
+
+	if arr.cache {
+		if arr.aValuesCache, err = arr.lookup(arr.a, aLen); err != nil {
+			return flaterrors.Join(err, ErrRecoveringState)
+		}
+
+		if arr.bValuesCache, err = arr.lookup(arr.b, bLen); err != nil {
+			return flaterrors.Join(err, ErrRecoveringState)
+		}
+	}
+
+	arr.activePointerCache = activePointer
+	arr.aLenCache = aLen
+	arr.bLenCache = bLen
+
 	return nil
 }
 
@@ -251,3 +459,64 @@ func (arr *bpfArray[T]) setPassiveLen(newLen uint32) error {
 	arr.aLenCache = newLen
 	return nil
 }
+
+func (arr *bpfArray[T]) cachePassiveValues(values []T) {
+	if arr.activePointerCache == 0 {
+		arr.bValuesCache = values
+		return
+	}
+	arr.aValuesCache = values
+}
+
+func (arr *bpfArray[T]) getActiveMap() *ebpf.Map {
+	if arr.activePointerCache == 0 {
+		return arr.a
+	}
+	return arr.b
+}
+
+// -------------------------------------------------------------------
+// -- SWITCHOVER MEMBER
+// -------------------------------------------------------------------
+
+var _ switchoverMember = &bpfArray[any]{}
+
+func (arr *bpfArray[T]) mutex() *sync.RWMutex {
+	return arr.mu
+}
+
+func (arr *bpfArray[T]) getActivePointer() variable {
+	return arr.activePointer
+}
+
+func (arr *bpfArray[T]) getActivePointerCache() uint8 {
+	return arr.activePointerCache
+}
+
+func (arr *bpfArray[T]) setActivePointerCache(v uint8) {
+	arr.activePointerCache = v
+}
+
+func (arr *bpfArray[T]) isSwitchoverPending() bool {
+	return arr.switchoverPending
+}
+
+func (arr *bpfArray[T]) setSwitchoverPending(v bool) {
+	arr.switchoverPending = v
+}
+
+func (arr *bpfArray[T]) restage() error {
+	var activeValuesCache []T
+	if arr.activePointerCache == 0 {
+		activeValuesCache = arr.aValuesCache
+	} else {
+		activeValuesCache = arr.bValuesCache
+	}
+
+	values, err := arr.get(arr.getActiveMap(), arr.getActiveLenFromCache(), activeValuesCache)
+	if err != nil {
+		return err
+	}
+
+	return arr.set(values)
+}
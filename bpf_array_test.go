@@ -0,0 +1,264 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// generationValues returns the values written by generation gen: a slice
+// of length gen%8+1 whose elements are all equal to gen. It allows readers
+// to detect a mix of values written by different generations.
+func generationValues(gen uint32) []uint32 {
+	values := make([]uint32, gen%8+1)
+	for i := range values {
+		values[i] = gen
+	}
+	return values
+}
+
+// assertGeneration fails if values have not been written by a single
+// generation.
+func assertGeneration(t *testing.T, values []uint32) {
+	t.Helper()
+
+	if len(values) == 0 {
+		return
+	}
+
+	if !slices.Equal(values, generationValues(values[0])) {
+		t.Errorf("values written by different generations: %v", values)
+	}
+}
+
+func TestArrayConcurrentAccess(t *testing.T) {
+	arr := newTestBPFArray(t, nil, nil)
+
+	var (
+		gen atomic.Uint32
+		wg  sync.WaitGroup
+	)
+
+	for range 4 {
+		wg.Add(3)
+
+		// -- Set
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				err := arr.Set(generationValues(gen.Add(1)))
+				if err != nil && !errors.Is(err, ErrSwitchoverPending) {
+					t.Error(err)
+				}
+			}
+		}()
+
+		// -- PrepareSwitchover & Commit or Abort
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				sw, err := arr.PrepareSwitchover(generationValues(gen.Add(1)))
+				if errors.Is(err, ErrSwitchoverPending) {
+					continue
+				} else if err != nil {
+					t.Error(err)
+					continue
+				}
+
+				if i%2 == 0 {
+					sw.Abort()
+					continue
+				}
+
+				if err := sw.Commit(); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+
+		// -- Get & GetPassive
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				values, err := arr.Get()
+				if err != nil {
+					t.Error(err)
+				}
+				assertGeneration(t, values)
+
+				values, err = arr.GetPassive()
+				if err != nil {
+					t.Error(err)
+				}
+				assertGeneration(t, values)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestArraySetWhileSwitchoverPending(t *testing.T) {
+	arr := newTestBPFArray(t, nil, nil)
+
+	if err := arr.Set([]uint32{1}); err != nil {
+		t.Fatal(err)
+	}
+
+	sw, err := arr.PrepareSwitchover([]uint32{2, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// -- updates are rejected while the switchover is pending.
+	if err := arr.Set([]uint32{3}); !errors.Is(err, ErrSwitchoverPending) {
+		t.Fatalf("Set: want ErrSwitchoverPending; got %v", err)
+	}
+
+	if _, err := arr.PrepareSwitchover([]uint32{3}); !errors.Is(err, ErrSwitchoverPending) {
+		t.Fatalf("PrepareSwitchover: want ErrSwitchoverPending; got %v", err)
+	}
+
+	if _, _, err := arr.SetAndDeferSwitchover([]uint32{3}); !errors.Is(err, ErrSwitchoverPending) {
+		t.Fatalf("SetAndDeferSwitchover: want ErrSwitchoverPending; got %v", err)
+	}
+
+	// -- the rejected updates did not modify the staged values.
+	assertArray(t, arr, []uint32{1}, []uint32{2, 2})
+
+	if err := sw.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertArray(t, arr, []uint32{2, 2}, []uint32{1})
+
+	// -- updates are accepted once the switchover has been committed.
+	if err := arr.Set([]uint32{3}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertArray(t, arr, []uint32{3}, []uint32{2, 2})
+
+	// -- updates are accepted once the switchover has been aborted.
+	sw, err = arr.PrepareSwitchover([]uint32{4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sw.Abort()
+
+	if err := arr.Set([]uint32{5}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertArray(t, arr, []uint32{5}, []uint32{3})
+}
+
+func assertArray(t *testing.T, arr *bpfArray[uint32], wantActive, wantPassive []uint32) {
+	t.Helper()
+
+	active, err := arr.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passive, err := arr.GetPassive()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(active, wantActive) || !slices.Equal(passive, wantPassive) {
+		t.Fatalf("want active %v & passive %v; got active %v & passive %v", wantActive, wantPassive, active, passive)
+	}
+}
+
+// failingVariable is a variable whose writes fail.
+type failingVariable struct {
+	variable
+}
+
+func (failingVariable) Set(any) error {
+	return errors.New("failing variable")
+}
+
+func TestArraySetAndDeferSwitchover(t *testing.T) {
+	arr := newTestBPFArray(t, nil, nil)
+
+	// -- abort releases the pending switchover.
+	commit, abort, err := arr.SetAndDeferSwitchover([]uint32{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	abort()
+
+	if err := arr.Set([]uint32{2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := commit(); !errors.Is(err, ErrSwitchoverClosed) {
+		t.Fatalf("want ErrSwitchoverClosed; got %v", err)
+	}
+
+	assertArray(t, arr, []uint32{2}, []uint32{})
+
+	// -- commit performs the switchover.
+	commit, _, err = arr.SetAndDeferSwitchover([]uint32{3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertArray(t, arr, []uint32{3}, []uint32{2})
+}
+
+func TestArraySetAndDeferSwitchoverCommitFails(t *testing.T) {
+	arr := newTestBPFArray(t,
+		failingVariable{newTestVariable(t, 1)},
+		nil,
+		WithRetryPolicy(RetryPolicy{MaxTries: 2}),
+	)
+
+	commit, abort, err := arr.SetAndDeferSwitchover([]uint32{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// -- commit returns an error instead of panicking, and the switchover
+	//    remains pending.
+	if err := commit(); !errors.Is(err, ErrCommittingSwitchover) {
+		t.Fatalf("want ErrCommittingSwitchover; got %v", err)
+	}
+
+	if _, err := arr.PrepareSwitchover([]uint32{2}); !errors.Is(err, ErrSwitchoverPending) {
+		t.Fatalf("want ErrSwitchoverPending; got %v", err)
+	}
+
+	abort()
+
+	sw, err := arr.PrepareSwitchover([]uint32{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw.Abort()
+}
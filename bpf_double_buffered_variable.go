@@ -0,0 +1,381 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/alexandremahdhaoui/ebpfstruct/internal/util"
+
+	"github.com/alexandremahdhaoui/tooling/pkg/flaterrors"
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+)
+
+var ErrCreatingNewDoubleBufferedVariable = errors.New("creating new double-buffered variable")
+
+// -------------------------------------------------------------------
+// -- DOUBLE-BUFFERED VARIABLE
+// -------------------------------------------------------------------
+
+// DoubleBufferedVariable[T] wraps 2 bpf variables `a` & `b` and an
+// "activePointer" bpf variable, like Array[T] & Map[K,V].
+//
+// It allows scalar configuration, e.g. a flag, to be updated
+// "pseudo-atomically" together with other bpf data structures sharing the
+// same "activePointer" bpf variable.
+//
+// Notes:
+//   - DoubleBufferedVariable is thread-safe.
+//   - While a deferred switchover is pending, i.e. SetAndDeferSwitchover
+//     or PrepareSwitchover returned but the switchover has not been
+//     performed or aborted yet, updates return ErrSwitchoverPending.
+type DoubleBufferedVariable[T any] interface {
+	// Done returns a channel that's closed when work done on behalf of this
+	// interface has been gracefully terminated.
+	Done() <-chan struct{}
+
+	// Close closes the channel returned by Done(). Subsequent updates
+	// return ErrDataStructureClosed.
+	//
+	// Closing the doneCh passed to the constructor closes the
+	// DoubleBufferedVariable.
+	// Close can be called multiple times.
+	Close() error
+
+	// Get returns the value of the ACTIVE variable, i.e. the value
+	// currently seen by the bpf program.
+	Get() (T, error)
+
+	// Set updates the PASSIVE variable then performs the switchover.
+	Set(v T) error
+
+	// GetPassive returns the value of the PASSIVE variable, e.g. a value
+	// staged by SetAndDeferSwitchover that is not yet seen by the bpf
+	// program.
+	GetPassive() (T, error)
+
+	// SetAndDeferSwitchover updates the passive variable but does not
+	// perform the switchover.
+	//
+	// Please refer to Array.SetAndDeferSwitchover.
+	SetAndDeferSwitchover(v T) (commit func() error, abort func(), err error)
+
+	// PrepareSwitchover updates the passive variable but does not perform
+	// the switchover.
+	//
+	// Please refer to Array.PrepareSwitchover.
+	PrepareSwitchover(v T) (Switchover, error)
+}
+
+// NewDoubleBufferedVariable returns a DoubleBufferedVariable[T] backed by
+// the a & b bpf variables. activePointer must be defined in the bpf
+// program as __u8:
+//   - When set to 0, the "active variable" is `a`.
+//   - When set to 1, the "active variable" is `b`.
+//
+// doneCh is a channel used to notify the bpf data structures or bpf
+// program has been closed and they can no longer be used.
+func NewDoubleBufferedVariable[T any](
+	a, b *ebpf.Variable,
+	activePointer *ebpf.Variable,
+	doneCh <-chan struct{},
+	opts ...Option,
+) (DoubleBufferedVariable[T], error) {
+	if util.AnyPtrIsNil(a, b, activePointer) {
+		return nil, flaterrors.Join(ErrEBPFObjectsMustNotBeNil, ErrCreatingNewDoubleBufferedVariable)
+	}
+
+	for _, v := range []struct {
+		name string
+		obj  *ebpf.Variable
+	}{{name: "a", obj: a}, {name: "b", obj: b}} {
+		var typ btf.Type
+		if v.obj.Type() != nil {
+			typ = v.obj.Type().Type
+		}
+
+		if err := validateType[T]("variable "+v.name, uint32(v.obj.Size()), typ); err != nil {
+			return nil, flaterrors.Join(err, ErrCreatingNewDoubleBufferedVariable)
+		}
+	}
+
+	if err := validateUintVariable("variable activePointer", activePointer, 1); err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewDoubleBufferedVariable)
+	}
+
+	return newDoubleBufferedVariable[T](a, b, activePointer, doneCh, opts...)
+}
+
+func newDoubleBufferedVariable[T any](
+	a, b variable,
+	activePointer variable,
+	doneCh <-chan struct{},
+	opts ...Option,
+) (DoubleBufferedVariable[T], error) {
+	o := newOptions(opts...)
+
+	dbv := &bpfDoubleBufferedVariable[T]{
+		a:                  a,
+		b:                  b,
+		activePointer:      activePointer,
+		activePointerCache: 0,
+		cache:              o.cache,
+		aValueCache:        *new(T),
+		bValueCache:        *new(T),
+		aCached:            false,
+		bCached:            false,
+		retryPolicy:        o.retryPolicy,
+		mu:                 &sync.RWMutex{},
+		switchoverPending:  false,
+		lc:                 nil,
+	}
+
+	if o.stateRecovery {
+		if err := dbv.recoverState(); err != nil {
+			return nil, flaterrors.Join(err, ErrCreatingNewDoubleBufferedVariable)
+		}
+	}
+
+	dbv.lc = newLifecycle(doneCh, o.closer)
+
+	return dbv, nil
+}
+
+type bpfDoubleBufferedVariable[T any] struct {
+	// a & b are the internal variables, either one or the other is in the
+	// active state while the other one is in passive state.
+	a, b variable
+
+	// activePointer must be defined in the bpf program as __u8.
+	activePointer variable
+	// We save a few syscalls by caching `activePointer` value instead of
+	// reading the bpf variable.
+	activePointerCache uint8
+
+	// cache enables serving Get() & GetPassive() from {a,b}ValueCache.
+	cache bool
+	// copies of the values written to the respective a or b variable. Only
+	// populated when cache is enabled.
+	aValueCache, bValueCache T
+	// aCached & bCached are true once the respective aValueCache or
+	// bValueCache is valid. Until then, the value is read from the kernel.
+	aCached, bCached bool
+
+	// retryPolicy configures how deferred switchovers are retried.
+	retryPolicy RetryPolicy
+
+	// mu protects all fields above from concurrent access. It is held for
+	// the whole duration of a set & switchover.
+	mu *sync.RWMutex
+	// switchoverPending is true while a deferred switchover has not been
+	// performed or aborted.
+	switchoverPending bool
+
+	// lc ends when Close() is called or when the doneCh passed to the
+	// constructor is closed.
+	lc *lifecycle
+}
+
+func (dbv *bpfDoubleBufferedVariable[T]) Done() <-chan struct{} {
+	return dbv.lc.Done()
+}
+
+func (dbv *bpfDoubleBufferedVariable[T]) Close() error {
+	return dbv.lc.Close()
+}
+
+// Get implements DoubleBufferedVariable.
+func (dbv *bpfDoubleBufferedVariable[T]) Get() (T, error) {
+	dbv.mu.RLock()
+	defer dbv.mu.RUnlock()
+
+	if dbv.activePointerCache == 0 {
+		return dbv.get(dbv.a, dbv.aValueCache, dbv.aCached)
+	}
+	return dbv.get(dbv.b, dbv.bValueCache, dbv.bCached)
+}
+
+// GetPassive implements DoubleBufferedVariable.
+func (dbv *bpfDoubleBufferedVariable[T]) GetPassive() (T, error) {
+	dbv.mu.RLock()
+	defer dbv.mu.RUnlock()
+
+	if dbv.activePointerCache == 0 {
+		return dbv.get(dbv.b, dbv.bValueCache, dbv.bCached)
+	}
+	return dbv.get(dbv.a, dbv.aValueCache, dbv.aCached)
+}
+
+// Set implements DoubleBufferedVariable.
+func (dbv *bpfDoubleBufferedVariable[T]) Set(v T) error {
+	dbv.mu.Lock()
+	defer dbv.mu.Unlock()
+
+	if dbv.lc.closed() {
+		return ErrDataStructureClosed
+	}
+
+	if dbv.switchoverPending {
+		return ErrSwitchoverPending
+	}
+
+	if err := dbv.set(v); err != nil {
+		return err
+	}
+
+	return dbv.switchover()
+}
+
+func (dbv *bpfDoubleBufferedVariable[T]) SetAndDeferSwitchover(v T) (func() error, func(), error) {
+	sw, err := dbv.PrepareSwitchover(v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sw.Commit, sw.Abort, nil
+}
+
+func (dbv *bpfDoubleBufferedVariable[T]) PrepareSwitchover(v T) (Switchover, error) {
+	dbv.mu.Lock()
+	defer dbv.mu.Unlock()
+
+	if dbv.lc.closed() {
+		return nil, ErrDataStructureClosed
+	}
+
+	if dbv.switchoverPending {
+		return nil, ErrSwitchoverPending
+	}
+
+	if err := dbv.set(v); err != nil {
+		return nil, err
+	}
+
+	dbv.switchoverPending = true
+
+	return newSwitchover(dbv, dbv.retryPolicy), nil
+}
+
+// set writes v to the passive variable.
+func (dbv *bpfDoubleBufferedVariable[T]) set(v T) error {
+	passive, passiveValueCache, passiveCached := dbv.b, &dbv.bValueCache, &dbv.bCached
+	if dbv.activePointerCache == 1 {
+		passive, passiveValueCache, passiveCached = dbv.a, &dbv.aValueCache, &dbv.aCached
+	}
+
+	if err := passive.Set(v); err != nil {
+		return err
+	}
+
+	if dbv.cache {
+		*passiveValueCache, *passiveCached = v, true
+	}
+
+	return nil
+}
+
+// get returns the value of obj. It performs no syscall if cache is
+// enabled and valueCache is valid, i.e. cached is true.
+func (dbv *bpfDoubleBufferedVariable[T]) get(obj variable, valueCache T, cached bool) (T, error) {
+	if dbv.cache && cached {
+		return valueCache, nil
+	}
+
+	var v T
+	if err := obj.Get(&v); err != nil {
+		return *new(T), err
+	}
+	return v, nil
+}
+
+// recoverState rebuilds the internal state from the kernel.
+func (dbv *bpfDoubleBufferedVariable[T]) recoverState() error {
+	activePointer, err := recoverActivePointer(dbv.activePointer)
+	if err != nil {
+		return err
+	}
+
+	if dbv.cache {
+		if err := dbv.a.Get(&dbv.aValueCache); err != nil {
+			return flaterrors.Join(err, ErrRecoveringState)
+		}
+
+		if err := dbv.b.Get(&dbv.bValueCache); err != nil {
+			return flaterrors.Join(err, ErrRecoveringState)
+		}
+
+		dbv.aCached, dbv.bCached = true, true
+	}
+
+	dbv.activePointerCache = activePointer
+
+	return nil
+}
+
+func (dbv *bpfDoubleBufferedVariable[T]) switchover() error {
+	newActive := 1 - dbv.activePointerCache
+	if err := dbv.activePointer.Set(newActive); err != nil {
+		return err
+	}
+	dbv.activePointerCache = newActive
+	return nil
+}
+
+// -------------------------------------------------------------------
+// -- SWITCHOVER MEMBER
+// -------------------------------------------------------------------
+
+var _ switchoverMember = &bpfDoubleBufferedVariable[any]{}
+
+func (dbv *bpfDoubleBufferedVariable[T]) mutex() *sync.RWMutex {
+	return dbv.mu
+}
+
+func (dbv *bpfDoubleBufferedVariable[T]) getActivePointer() variable {
+	return dbv.activePointer
+}
+
+func (dbv *bpfDoubleBufferedVariable[T]) getActivePointerCache() uint8 {
+	return dbv.activePointerCache
+}
+
+func (dbv *bpfDoubleBufferedVariable[T]) setActivePointerCache(v uint8) {
+	dbv.activePointerCache = v
+}
+
+func (dbv *bpfDoubleBufferedVariable[T]) isSwitchoverPending() bool {
+	return dbv.switchoverPending
+}
+
+func (dbv *bpfDoubleBufferedVariable[T]) setSwitchoverPending(v bool) {
+	dbv.switchoverPending = v
+}
+
+func (dbv *bpfDoubleBufferedVariable[T]) restage() error {
+	active, activeValueCache, activeCached := dbv.a, dbv.aValueCache, dbv.aCached
+	if dbv.activePointerCache == 1 {
+		active, activeValueCache, activeCached = dbv.b, dbv.bValueCache, dbv.bCached
+	}
+
+	v, err := dbv.get(active, activeValueCache, activeCached)
+	if err != nil {
+		return err
+	}
+
+	return dbv.set(v)
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"testing"
+)
+
+func TestDoubleBufferedVariableInitialValueWithCache(t *testing.T) {
+	activePointer := newTestVariable(t, 1)
+	a, b := newTestVariable(t, 4), newTestVariable(t, 4)
+
+	// -- the initial value of the bpf variables, e.g. set by the bpf
+	//    program.
+	if err := a.Set(uint32(42)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Set(uint32(7)); err != nil {
+		t.Fatal(err)
+	}
+
+	dbv, err := newDoubleBufferedVariable[uint32](a, b, activePointer, nil, WithCache())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbv.Close()
+
+	assertDoubleBufferedVariable(t, dbv, 42, 7)
+
+	// -- restaging the never-written active side preserves its value.
+	arr := newTestBPFArray(t, activePointer, nil)
+
+	g, err := NewSwitchoverGroup(arr, dbv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.Commit(StageArray(Array[uint32](arr), []uint32{1})); err != nil {
+		t.Fatal(err)
+	}
+
+	assertDoubleBufferedVariable(t, dbv, 42, 42)
+
+	// -- values written by this process are served from the cache.
+	if err := dbv.Set(3); err != nil {
+		t.Fatal(err)
+	}
+
+	assertDoubleBufferedVariable(t, dbv, 3, 42)
+}
+
+func assertDoubleBufferedVariable(t *testing.T, dbv DoubleBufferedVariable[uint32], wantActive, wantPassive uint32) {
+	t.Helper()
+
+	active, err := dbv.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passive, err := dbv.GetPassive()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if active != wantActive || passive != wantPassive {
+		t.Fatalf("want active %d & passive %d; got active %d & passive %d", wantActive, wantPassive, active, passive)
+	}
+}
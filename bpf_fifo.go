@@ -16,16 +16,20 @@
 package ebpfstruct
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 	"errors"
+	"io"
 	"log/slog"
+	"maps"
+	"os"
+	"slices"
 	"sync"
+	"sync/atomic"
 
 	"github.com/alexandremahdhaoui/tooling/pkg/flaterrors"
 
 	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/perf"
 	"github.com/cilium/ebpf/ringbuf"
 )
 
@@ -36,30 +40,122 @@ import (
 // - T must be a **struct**.
 // - T must not be a pointer.
 // - T must not be an interface.
+// - T must have a fixed size, unless a Decoder[T] is provided.
 //
 // Notes:
-// - FIFO is thread-safe.
-// - Subscribe() can be called only once.
+//   - FIFO is thread-safe.
+//   - Subscriber channels are unbuffered and block the reader by default,
+//     unless the WithBroadcast() option is used. Please use the
+//     WithBufferSize() and WithOverflowPolicy() options to avoid stalling
+//     the reader when consumers are slow.
+//   - Subscribe(), SubscribeWithErrors() or SubscribeBatch() can be called
+//     only once, unless the FIFO was created with the WithBroadcast()
+//     option.
 type FIFO[T any] interface {
 	// Done returns a channel that's closed when work done on behalf of this
 	// interface has been gracefully terminated.
 	//
-	// While the channel from Subscribe() could be used to know if the FIFO
-	// interface has been closed, using the channel from this interface is
-	// more idiomatic.
+	// The channel is closed after the FIFO has been closed, i.e. by calling
+	// Close(), by cancelling the context passed to Subscribe() or by closing
+	// the doneCh passed to NewFIFO(), and after the goroutine started by
+	// Subscribe() has exited.
+	//
+	// In broadcast mode, cancelling the context passed to Subscribe() does
+	// not close the FIFO.
 	Done() <-chan struct{}
 
 	// Subscribe returns a receiver channel of T or an error.
-	Subscribe() (<-chan T, error)
+	//
+	// The receiver channel is closed when the FIFO is closed. Cancelling ctx
+	// closes the FIFO, or only unsubscribes in broadcast mode.
+	//
+	// Records that cannot be read or decoded are logged and dropped. Please
+	// use SubscribeWithErrors to handle these errors.
+	Subscribe(ctx context.Context) (<-chan T, error)
+
+	// SubscribeWithErrors returns a receiver channel of FIFOResult[T] or an
+	// error.
+	//
+	// Records that cannot be decoded are dropped and reported as a
+	// FIFOResult[T] with a transient error. If the ring buffer cannot be read
+	// anymore, a FIFOResult[T] with a terminal error is sent and the FIFO is
+	// closed. Please use IsTerminalFIFOError to distinguish them.
+	//
+	// The receiver channel is closed when the FIFO is closed. Cancelling ctx
+	// closes the FIFO, or only unsubscribes in broadcast mode.
+	SubscribeWithErrors(ctx context.Context) (<-chan FIFOResult[T], error)
+
+	// Stats returns the counters of the FIFO, e.g. the number of records
+	// dropped because of the OverflowPolicy.
+	Stats() FIFOStats
+
+	// SubscribeBatch returns a receiver channel of []T or an error.
+	//
+	// Records are delivered in batches of at most the size configured with
+	// the WithBatchSize() option. A batch is delivered as soon as it is full
+	// or as soon as no more records are pending in the bpf map.
+	//
+	// Batches are taken from a pool. Once a batch has been processed, it
+	// can be returned to the pool with RecycleBatch() to avoid allocations.
+	//
+	// Records that cannot be read or decoded are logged and dropped.
+	//
+	// The receiver channel is closed when the FIFO is closed. Cancelling ctx
+	// closes the FIFO, or only unsubscribes in broadcast mode.
+	SubscribeBatch(ctx context.Context) (<-chan []T, error)
+
+	// RecycleBatch returns a batch received from SubscribeBatch() to the
+	// pool. The batch must not be used after calling RecycleBatch().
+	RecycleBatch(batch []T)
+
+	// Close stops the reader goroutine, closes the underlying reader and
+	// waits until the channel returned by Done() is closed.
+	//
+	// Close can be called multiple times.
+	Close() error
+}
+
+// FIFOResult[T] is a record received from a FIFO[T] or an error.
+type FIFOResult[T any] struct {
+	// Value is the decoded record. It is the zero value of T if Err is not
+	// nil.
+	Value T
+	// Err is the error encountered while reading or decoding the record.
+	Err error
+	// CPU is the CPU the record originates from. It is only reported by
+	// FIFOs created with NewPerfFIFO() and is -1 otherwise.
+	CPU int
 }
 
 type bpfFifo[T any] struct {
-	rb    *ringbuf.Reader
-	mu    *sync.Mutex
-	inUse bool
-	// doneCh is a channel used to notify the bpf data structures or bpf
-	// program has been closed and they can no longer be used.
-	doneCh <-chan struct{}
+	rd      fifoReader
+	decoder Decoder[T]
+	mu      *sync.Mutex
+	// inUse is true once the reader goroutine has been started.
+	inUse       bool
+	subscribers []*fifoSubscriber[T]
+	// closed is true once the FIFO has been closed. It can no longer be
+	// subscribed to.
+	closed bool
+
+	bufferSize     int
+	overflowPolicy OverflowPolicy
+	broadcast      bool
+	batchSize      int
+	// batchPool holds batches recycled with RecycleBatch().
+	batchPool chan []T
+	dropped   *atomic.Uint64
+	// lost counts the samples lost by the kernel per CPU. It is guarded by
+	// mu.
+	lost map[int]uint64
+
+	// stopCh is closed when the FIFO is being closed.
+	stopCh   chan struct{}
+	stopOnce *sync.Once
+	stopErr  error
+	// terminatedCh is closed once the FIFO has been closed and the
+	// reader goroutine, if any, has exited.
+	terminatedCh chan struct{}
 }
 
 // Generics constraints:
@@ -68,66 +164,333 @@ type bpfFifo[T any] struct {
 // - T must not be an interface.
 //
 // doneCh is a channel used to notify the bpf data structures or bpf
-// program has been closed and they can no longer be used.
-func NewFIFO[T any](ringbufMap *ebpf.Map, doneCh <-chan struct{}) (FIFO[T], error) {
+// program has been closed and they can no longer be used. Closing doneCh
+// closes the FIFO.
+//
+// Buffering can be configured using the WithBufferSize() and
+// WithOverflowPolicy() options. Multiple subscribers can be enabled using
+// the WithBroadcast() option. Records are decoded with the BinaryDecoder
+// unless the WithDecoder() option is used.
+func NewFIFO[T any](ringbufMap *ebpf.Map, doneCh <-chan struct{}, opts ...Option) (FIFO[T], error) {
+	if ringbufMap == nil {
+		return nil, flaterrors.Join(ErrEBPFObjectsMustNotBeNil, ErrCreatingNewFIFO)
+	}
+
+	o := newOptions(opts...)
+
+	decoder, err := newDecoder[T]("ring buffer: record", o)
+	if err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewFIFO)
+	}
+
 	rb, err := ringbuf.NewReader(ringbufMap)
 	if err != nil {
-		return nil, err
+		return nil, flaterrors.Join(err, ErrCreatingNewFIFO)
 	}
 
-	return &bpfFifo[T]{
-		rb:     rb,
-		mu:     &sync.Mutex{},
-		inUse:  false,
-		doneCh: doneCh,
-	}, nil
+	return newFIFO(&ringbufFIFOReader{rd: rb}, decoder, doneCh, o), nil
+}
+
+func newFIFO[T any](rd fifoReader, decoder Decoder[T], doneCh <-chan struct{}, o options) *bpfFifo[T] {
+	if o.recorder != nil {
+		rd = newRecordingFIFOReader(rd, o.recorder)
+	}
+
+	if o.broadcast && o.bufferSize == 0 {
+		o.bufferSize = DefaultBroadcastBufferSize
+	}
+
+	f := &bpfFifo[T]{
+		rd:             rd,
+		decoder:        decoder,
+		mu:             &sync.Mutex{},
+		inUse:          false,
+		subscribers:    nil,
+		closed:         false,
+		bufferSize:     o.bufferSize,
+		overflowPolicy: o.overflowPolicy,
+		broadcast:      o.broadcast,
+		batchSize:      o.batchSize,
+		batchPool:      make(chan []T, o.bufferSize+2),
+		dropped:        &atomic.Uint64{},
+		lost:           nil,
+		stopCh:         make(chan struct{}),
+		stopOnce:       &sync.Once{},
+		terminatedCh:   make(chan struct{}),
+	}
+
+	if doneCh != nil {
+		go f.closeOnSignal(doneCh)
+	}
+
+	return f
 }
 
 func (f *bpfFifo[T]) Done() <-chan struct{} {
-	return f.doneCh
+	return f.terminatedCh
 }
 
 var (
+	ErrCreatingNewFIFO                 = errors.New("creating new fifo")
 	ErrAnotherProcessAlreadySubscribed = errors.New("another process already subscribed")
+	ErrFIFOClosed                      = errors.New("fifo is closed")
 	ErrSubscribingToFIFO               = errors.New("subscribing to fifo")
+	ErrClosingFIFO                     = errors.New("closing fifo")
+
+	// Transient errors: the subscription goes on.
+	ErrReadingFIFO        = errors.New("reading from fifo")
+	ErrDecodingFIFORecord = errors.New("decoding fifo record")
+
+	// Terminal errors: the FIFO is closed.
+	ErrFIFOTerminated = errors.New("fifo terminated")
 )
 
-func (f *bpfFifo[T]) Subscribe() (<-chan T, error) {
+// IsTerminalFIFOError returns true if err was reported by a FIFO that can no
+// longer be read from, e.g. if the underlying ring buffer has been closed.
+func IsTerminalFIFOError(err error) bool {
+	return errors.Is(err, ErrFIFOTerminated)
+}
+
+func (f *bpfFifo[T]) Subscribe(ctx context.Context) (<-chan T, error) {
+	ch := make(chan T, f.bufferSize)
+
+	emit := func(res FIFOResult[T]) bool {
+		if res.Err != nil {
+			slog.ErrorContext(
+				ctx,
+				"an error occured reading from bpf ring buffer",
+				"err",
+				res.Err.Error(),
+			)
+			return true
+		}
+		return deliver(ch, res.Value, f.stopCh, f.subscriberPolicy(res), f.dropped)
+	}
+
+	sub := newFIFOSubscriber(emit, nil, func() { close(ch) })
+	if err := f.subscribe(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	return ch, nil
+}
+
+func (f *bpfFifo[T]) SubscribeWithErrors(ctx context.Context) (<-chan FIFOResult[T], error) {
+	ch := make(chan FIFOResult[T], f.bufferSize)
+
+	emit := func(res FIFOResult[T]) bool {
+		return deliver(ch, res, f.stopCh, f.subscriberPolicy(res), f.dropped)
+	}
+
+	sub := newFIFOSubscriber(emit, nil, func() { close(ch) })
+	if err := f.subscribe(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	return ch, nil
+}
+
+func (f *bpfFifo[T]) Stats() FIFOStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var lost uint64
+	for _, n := range f.lost {
+		lost += n
+	}
+
+	return FIFOStats{
+		Dropped:    f.dropped.Load(),
+		Lost:       lost,
+		LostPerCPU: maps.Clone(f.lost),
+	}
+}
+
+func (f *bpfFifo[T]) Close() error {
+	err := f.stop()
+	<-f.terminatedCh
+	return err
+}
+
+// subscribe registers sub and starts the reader goroutine if needed.
+func (f *bpfFifo[T]) subscribe(ctx context.Context, sub *fifoSubscriber[T]) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
-	if f.inUse {
-		return nil, flaterrors.Join(ErrAnotherProcessAlreadySubscribed, ErrSubscribingToFIFO)
-	}
-	f.inUse = true
-
-	ch := make(chan T) // TODO: buffer it.
-
-	go func() {
-		for {
-			rec, err := f.rb.Read()
-			if err != nil {
-				slog.ErrorContext(
-					context.TODO(),
-					"an unexpected error occured reading from bpf ring buffer",
-					"err",
-					err.Error(),
-				)
+	if f.closed {
+		return flaterrors.Join(ErrFIFOClosed, ErrSubscribingToFIFO)
+	}
+	if f.inUse && !f.broadcast {
+		return flaterrors.Join(ErrAnotherProcessAlreadySubscribed, ErrSubscribingToFIFO)
+	}
+
+	// f.subscribers is copied on write: publish() reads it without
+	// cloning it.
+	f.subscribers = append(slices.Clip(f.subscribers), sub)
+
+	if f.broadcast {
+		go f.unsubscribeOnSignal(ctx.Done(), sub)
+	} else {
+		go f.closeOnSignal(ctx.Done())
+	}
+
+	if !f.inUse {
+		f.inUse = true
+		go f.read()
+	}
+
+	return nil
+}
+
+// read reads records from the bpf map and publishes them to the
+// subscribers until the FIFO is closed. It closes the subscribers and
+// f.terminatedCh before returning.
+//
+// The record buffers are reused; records that cannot be decoded are
+// dropped.
+func (f *bpfFifo[T]) read() {
+	defer close(f.terminatedCh)
+	defer f.closeSubscribers()
+
+	var (
+		rec  fifoRecord
+		v    T
+		zero T
+	)
+
+	for {
+		err := f.rd.ReadInto(&rec)
+		switch {
+		case err == nil:
+			// isTransientReadError allocates: check err first.
+		case errors.Is(err, os.ErrClosed):
+			return
+		case errors.Is(err, io.EOF):
+			// The reader has no more records, e.g. a replay has ended.
+			_ = f.stop()
+			return
+		case isTransientReadError(err):
+			if !f.publish(FIFOResult[T]{Err: flaterrors.Join(err, ErrReadingFIFO), CPU: rec.cpu}) {
+				return
+			}
+			continue
+		case err != nil:
+			f.publish(FIFOResult[T]{Err: flaterrors.Join(err, ErrReadingFIFO, ErrFIFOTerminated), CPU: rec.cpu})
+			_ = f.stop()
+			return
+		}
+
+		if rec.lostSamples > 0 {
+			f.mu.Lock()
+			if f.lost == nil {
+				f.lost = make(map[int]uint64)
 			}
+			f.lost[rec.cpu] += rec.lostSamples
+			f.mu.Unlock()
+		} else {
+			// Reset v, the decoder may reuse its fields otherwise.
+			v = zero
 
-			v := new(T)
+			res := FIFOResult[T]{CPU: rec.cpu}
+			if err := f.decoder.Decode(rec.rawSample, &v); err != nil {
+				res.Err = flaterrors.Join(err, ErrDecodingFIFORecord)
+			} else {
+				res.Value = v
+			}
 
-			if err := binary.Read(bytes.NewReader(rec.RawSample), binary.NativeEndian, v); err != nil {
-				slog.ErrorContext(
-					context.TODO(),
-					"an error occured decoding record from bpf ring buffer",
-					"err",
-					err.Error(),
-				)
+			if !f.publish(res) {
+				return
 			}
+		}
 
-			ch <- *v
+		if rec.remaining == 0 && !f.flush() {
+			return
 		}
-	}()
+	}
+}
 
-	return ch, nil
+// closeOnSignal closes the FIFO when signal is closed. It returns early if
+// the FIFO is closed by other means.
+func (f *bpfFifo[T]) closeOnSignal(signal <-chan struct{}) {
+	select {
+	case <-signal:
+		_ = f.stop()
+	case <-f.stopCh:
+	}
+}
+
+// stop closes the FIFO. The underlying reader is closed, which interrupts
+// the reader goroutine. If no goroutine was started, stop closes
+// f.terminatedCh itself.
+func (f *bpfFifo[T]) stop() error {
+	f.stopOnce.Do(func() {
+		f.mu.Lock()
+		f.closed = true
+		subscribed := f.inUse
+		f.mu.Unlock()
+
+		close(f.stopCh)
+
+		if err := f.rd.Close(); err != nil {
+			f.stopErr = flaterrors.Join(err, ErrClosingFIFO)
+		}
+
+		if !subscribed {
+			close(f.terminatedCh)
+		}
+	})
+
+	return f.stopErr
+}
+
+// -------------------------------------------------------------------
+// -- FIFO READER
+// -------------------------------------------------------------------
+
+// fifoRecord is a raw record read from a bpf map. Its buffer is reused by
+// subsequent reads.
+type fifoRecord struct {
+	rawSample []byte
+	// cpu is the CPU the record originates from, or -1 if unknown.
+	cpu int
+	// lostSamples is the number of samples lost by the kernel. rawSample is
+	// empty if lostSamples is greater than 0.
+	lostSamples uint64
+	// remaining is the number of bytes pending in the bpf map after this
+	// record.
+	remaining int
+}
+
+// fifoReader reads raw records from a bpf map. Calling Close() interrupts
+// ReadInto(), which must then return os.ErrClosed. ReadInto() returns
+// io.EOF if no more records can be read.
+type fifoReader interface {
+	ReadInto(rec *fifoRecord) error
+	Close() error
+}
+
+type ringbufFIFOReader struct {
+	rd *ringbuf.Reader
+	// rec is reused by each call to ReadInto.
+	rec ringbuf.Record
+}
+
+func (r *ringbufFIFOReader) ReadInto(rec *fifoRecord) error {
+	err := r.rd.ReadInto(&r.rec)
+	rec.rawSample = r.rec.RawSample
+	rec.cpu = -1
+	rec.lostSamples = 0
+	rec.remaining = r.rec.Remaining
+	return err
+}
+
+func (r *ringbufFIFOReader) Close() error {
+	return r.rd.Close()
+}
+
+// isTransientReadError returns true if err does not prevent the reader from
+// reading subsequent records.
+func isTransientReadError(err error) bool {
+	return errors.Is(err, ringbuf.ErrFlushed) ||
+		errors.Is(err, os.ErrDeadlineExceeded) ||
+		perf.IsUnknownEvent(err)
 }
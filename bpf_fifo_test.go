@@ -0,0 +1,168 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+	"testing"
+)
+
+// fakeFIFOReader is a fifoReader yielding n copies of sample, then io.EOF.
+// If n is negative, ReadInto blocks until the reader is closed.
+type fakeFIFOReader struct {
+	sample []byte
+	n      int
+
+	closeCh   chan struct{}
+	closeOnce *sync.Once
+}
+
+func newFakeFIFOReader(sample []byte, n int) *fakeFIFOReader {
+	return &fakeFIFOReader{
+		sample:    sample,
+		n:         n,
+		closeCh:   make(chan struct{}),
+		closeOnce: &sync.Once{},
+	}
+}
+
+func (r *fakeFIFOReader) ReadInto(rec *fifoRecord) error {
+	select {
+	case <-r.closeCh:
+		return os.ErrClosed
+	default:
+	}
+
+	if r.n < 0 {
+		<-r.closeCh
+		return os.ErrClosed
+	}
+
+	if r.n == 0 {
+		return io.EOF
+	}
+
+	r.n--
+	rec.rawSample = append(rec.rawSample[:0], r.sample...)
+	rec.cpu = -1
+	rec.lostSamples = 0
+	rec.remaining = r.n * len(r.sample)
+
+	return nil
+}
+
+func (r *fakeFIFOReader) Close() error {
+	r.closeOnce.Do(func() { close(r.closeCh) })
+	return nil
+}
+
+type benchmarkEvent struct {
+	ID      uint32
+	Flags   uint32
+	Counter uint64
+	Addr    [16]byte
+}
+
+func benchmarkDecoders() []struct {
+	name    string
+	decoder Decoder[benchmarkEvent]
+} {
+	return []struct {
+		name    string
+		decoder Decoder[benchmarkEvent]
+	}{
+		{
+			// binary.Read is how records were decoded before decoders were
+			// pluggable.
+			name: "binary.Read",
+			decoder: DecoderFunc[benchmarkEvent](func(raw []byte, v *benchmarkEvent) error {
+				return binary.Read(bytes.NewReader(raw), binary.NativeEndian, v)
+			}),
+		},
+		{name: "BinaryDecoder", decoder: BinaryDecoder[benchmarkEvent]()},
+		{name: "UnsafeDecoder", decoder: UnsafeDecoder[benchmarkEvent]()},
+	}
+}
+
+func newBenchmarkFIFO(b *testing.B, decoder Decoder[benchmarkEvent], opts ...Option) *bpfFifo[benchmarkEvent] {
+	b.Helper()
+
+	sample, err := binary.Append(nil, binary.NativeEndian, benchmarkEvent{ID: 1, Counter: 2})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	f := newFIFO(newFakeFIFOReader(sample, b.N), decoder, nil, newOptions(opts...))
+	b.Cleanup(func() { _ = f.Close() })
+
+	return f
+}
+
+func BenchmarkSubscribe(b *testing.B) {
+	for _, bc := range benchmarkDecoders() {
+		b.Run(bc.name, func(b *testing.B) {
+			f := newBenchmarkFIFO(b, bc.decoder, WithBufferSize(128))
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			ch, err := f.Subscribe(context.Background())
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			n := 0
+			for range ch {
+				n++
+			}
+
+			if n != b.N {
+				b.Fatalf("want %d records; got %d", b.N, n)
+			}
+		})
+	}
+}
+
+func BenchmarkSubscribeBatch(b *testing.B) {
+	for _, bc := range benchmarkDecoders() {
+		b.Run(bc.name, func(b *testing.B) {
+			f := newBenchmarkFIFO(b, bc.decoder, WithBufferSize(128), WithBatchSize(64))
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			ch, err := f.SubscribeBatch(context.Background())
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			n := 0
+			for batch := range ch {
+				n += len(batch)
+				f.RecycleBatch(batch)
+			}
+
+			if n != b.N {
+				b.Fatalf("want %d records; got %d", b.N, n)
+			}
+		})
+	}
+}
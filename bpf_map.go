@@ -16,33 +16,82 @@
 package ebpfstruct
 
 import (
-	"log/slog"
+	"errors"
+	"iter"
 	"sync"
-	"time"
 
 	"github.com/alexandremahdhaoui/ebpfstruct/internal/util"
 
+	"github.com/alexandremahdhaoui/tooling/pkg/flaterrors"
 	"github.com/cilium/ebpf"
 )
 
+var ErrCreatingNewMap = errors.New("creating new map")
+
+// batchLookupSize is the number of entries read per LOOKUP_BATCH syscall
+// when iterating over a map.
+const batchLookupSize = 1024
+
 // -------------------------------------------------------------------
 // -- BPF MAP
 // -------------------------------------------------------------------
 
 // Wraps bpf objects with a convenient interface for testing.
+//
+// Notes:
+//   - Map is thread-safe.
+//   - While a deferred switchover is pending, i.e. SetAndDeferSwitchover
+//     or PrepareSwitchover returned but the switchover has not been
+//     performed or aborted yet, updates return ErrSwitchoverPending.
 type Map[K comparable, V any] interface {
 	// Done returns a channel that's closed when work done on behalf of this
 	// interface has been gracefully terminated.
 	Done() <-chan struct{}
 
+	// Close releases the resources owned by the Map, e.g. the bpf objects
+	// loaded by NewMapFromPinPath, and closes the channel returned by Done().
+	// Subsequent updates return ErrDataStructureClosed.
+	//
+	// Closing the doneCh passed to the constructor closes the Map.
+	// Close can be called multiple times.
+	Close() error
+
+	// Lookup returns the value associated with k in the ACTIVE map.
+	// The returned bool is false if k does not exist in the ACTIVE map.
+	Lookup(k K) (V, bool, error)
+
+	// GetAll returns all entries of the ACTIVE map.
+	// Entries are read in batch from the ACTIVE map. The Map is locked for
+	// the whole lookup, hence the returned entries are consistent.
+	GetAll() (map[K]V, error)
+
+	// Iterate returns an iterator over the entries of the ACTIVE map and a
+	// function returning the error that stopped the iteration, if any. If
+	// the iterator is ranged over many times, the function reports the
+	// error of the last iteration that completed.
+	//
+	// Entries are streamed in batches, hence large maps can be iterated
+	// without being materialized in userspace.
+	// The ACTIVE map is selected when the iteration starts.
+	//
+	// Unlike GetAll, Iterate does not lock the Map while yielding entries,
+	// hence concurrent updates, e.g. BatchUpdate or Set followed by another
+	// Set, may be observed partially: the iteration may yield a mix of old
+	// and new entries.
+	Iterate() (iter.Seq2[K, V], func() error)
+
 	// Update in batch a set of entries in the ACTIVE map.
 	// This method does not perform a switchover.
 	// This method mutates the ACTIVE map.
+	// The length of the ACTIVE map is updated accordingly, hence it is safe
+	// to mix incremental updates with calls to Set.
 	BatchUpdate(kv map[K]V) error
 
 	// Delete in batch a set of keys from the ACTIVE map.
 	// This method does not perform a switchover.
 	// This method mutates the ACTIVE map.
+	// The length of the ACTIVE map is updated accordingly, hence it is safe
+	// to mix incremental updates with calls to Set.
 	BatchDelete([]K) error
 
 	// Set all values of the BPF map to the one of the input map.
@@ -55,17 +104,23 @@ type Map[K comparable, V any] interface {
 	// structures from the bpf-program point of view, by sharing the same
 	// "activePointer" bpf variable with multiple BPF data structures.
 	//
-	// SetAndDeferSwitchover returns a function that can be called once to
+	// SetAndDeferSwitchover returns a commit function performing the
+	// switchover and an abort function discarding the staged data. They
+	// behave like Switchover.Commit() and Switchover.Abort(): commit
+	// retries if errors are encountered and returns an error if the
+	// switchover cannot be performed.
+	//
+	// Either commit or abort must be called, even if the same
+	// "activePointer" bpf variable is used for multiple data structures:
+	// they update internal variables in userspace, and updates return
+	// ErrSwitchoverPending until one of them is called.
+	SetAndDeferSwitchover(newMap map[K]V) (commit func() error, abort func(), err error)
+
+	// PrepareSwitchover updates the passive internal map but does not
 	// perform the switchover.
-	// The returned function can only be called once.
-	// Please note that the returned function will retry if errors are
-	// encountered.
 	//
-	// The deferable switchover function must be called, even if the same
-	// "activePointer" bpf variable is used for multiple data structures.
-	// The deferable switchover function must be called because it updates
-	// internal variables in userspace.
-	SetAndDeferSwitchover(newMap map[K]V) (func(), error)
+	// Either Switchover.Commit() or Switchover.Abort() must be called.
+	PrepareSwitchover(newMap map[K]V) (Switchover, error)
 }
 
 type bpfMap[K comparable, V any] struct {
@@ -92,19 +147,29 @@ type bpfMap[K comparable, V any] struct {
 	// the bpf variable storing the length of the respective a or b map.
 	// We don't need to cache {a,b}Len as they can safely be retrieved from
 	// from {a,b}KeysCache.
-	aLen, bLen *ebpf.Variable
+	aLen, bLen variable
 
 	// activePointer must be defined in the bpf program as __u8.
 	// - When set to 0, the "active map" is `a` & the "active length" is `aLen`.
 	// - When set to 1, the "active map" is `b` & the "active length" is `bLen`.
-	activePointer *ebpf.Variable
+	activePointer variable
 	// We save a few syscalls by caching `activePointer` value instead of reading
 	// the bpf variable.
 	activePointerCache uint8
 
-	// doneCh is a channel used to notify the bpf data structures or bpf
-	// program has been closed and they can no longer be used.
-	doneCh <-chan struct{}
+	// retryPolicy configures how deferred switchovers are retried.
+	retryPolicy RetryPolicy
+
+	// mu protects all fields above from concurrent access. It is held for
+	// the whole duration of a set & switchover.
+	mu *sync.RWMutex
+	// switchoverPending is true while the function returned by
+	// SetAndDeferSwitchover has not been called.
+	switchoverPending bool
+
+	// lc ends when Close() is called or when the doneCh passed to the
+	// constructor is closed.
+	lc *lifecycle
 }
 
 // doneCh is a channel used to notify the bpf data structures or bpf
@@ -114,12 +179,33 @@ func NewMap[K comparable, V any](
 	aLen, bLen *ebpf.Variable,
 	activePointer *ebpf.Variable,
 	doneCh <-chan struct{},
+	opts ...Option,
 ) (Map[K, V], error) {
 	if util.AnyPtrIsNil(a, b, aLen, bLen, activePointer) {
-		return nil, ErrEBPFObjectsMustNotBeNil
+		return nil, flaterrors.Join(ErrEBPFObjectsMustNotBeNil, ErrCreatingNewMap)
+	}
+
+	if err := validateControlVariables(aLen, bLen, activePointer); err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewMap)
+	}
+
+	return newMap[K, V](a, b, aLen, bLen, activePointer, doneCh, opts...)
+}
+
+func newMap[K comparable, V any](
+	a, b *ebpf.Map,
+	aLen, bLen variable,
+	activePointer variable,
+	doneCh <-chan struct{},
+	opts ...Option,
+) (Map[K, V], error) {
+	if err := validateMapMaps[K, V](a, b); err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewMap)
 	}
 
-	return &bpfMap[K, V]{
+	o := newOptions(opts...)
+
+	m := &bpfMap[K, V]{
 		a:                  a,
 		b:                  b,
 		aKeysCache:         make(map[K]struct{}),
@@ -128,12 +214,74 @@ func NewMap[K comparable, V any](
 		bLen:               bLen,
 		activePointer:      activePointer,
 		activePointerCache: 0,
-		doneCh:             doneCh,
-	}, nil
+		retryPolicy:        o.retryPolicy,
+		mu:                 &sync.RWMutex{},
+		switchoverPending:  false,
+		lc:                 nil,
+	}
+
+	if o.stateRecovery {
+		if err := m.recoverState(); err != nil {
+			return nil, flaterrors.Join(err, ErrCreatingNewMap)
+		}
+	}
+
+	m.lc = newLifecycle(doneCh, o.closer)
+
+	return m, nil
 }
 
 func (m *bpfMap[K, V]) Done() <-chan struct{} {
-	return m.doneCh
+	return m.lc.Done()
+}
+
+func (m *bpfMap[K, V]) Close() error {
+	return m.lc.Close()
+}
+
+func (m *bpfMap[K, V]) Lookup(k K) (V, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var v V
+	if err := m.getActiveMap().Lookup(k, &v); errors.Is(err, ebpf.ErrKeyNotExist) {
+		return v, false, nil
+	} else if err != nil {
+		return v, false, err
+	}
+	return v, true, nil
+}
+
+func (m *bpfMap[K, V]) GetAll() (map[K]V, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.getAll(m.getActiveMap())
+}
+
+func (m *bpfMap[K, V]) Iterate() (iter.Seq2[K, V], func() error) {
+	// The iterator may be ranged over many times, possibly concurrently:
+	// each iteration keeps its own error and reports it once done.
+	mu := &sync.Mutex{}
+	var lastErr error
+
+	seq := func(yield func(K, V) bool) {
+		m.mu.RLock()
+		active := m.getActiveMap()
+		m.mu.RUnlock()
+
+		err := m.iterate(active, yield)
+
+		mu.Lock()
+		lastErr = err
+		mu.Unlock()
+	}
+
+	return seq, func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		return lastErr
+	}
 }
 
 func (m *bpfMap[K, V]) BatchUpdate(kv map[K]V) error {
@@ -144,22 +292,68 @@ func (m *bpfMap[K, V]) BatchUpdate(kv map[K]V) error {
 		values[i] = v
 		i++
 	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lc.closed() {
+		return ErrDataStructureClosed
+	}
+
 	active := m.getActiveMap()
-	if _, err := active.BatchUpdate(keys, values, nil); err != nil {
-		return err
+	n, err := active.BatchUpdate(keys, values, nil)
+
+	// The kernel processes keys in order and stops at the first failure,
+	// hence the first n keys have been updated even if an error occured.
+	activeKeys := m.getActiveKeysFromCache()
+	for _, k := range keys[:n] {
+		activeKeys[k] = struct{}{}
 	}
-	return nil
+
+	if err != nil {
+		return flaterrors.Join(err, m.setActiveLen(uint32(len(activeKeys))))
+	}
+
+	return m.setActiveLen(uint32(len(activeKeys)))
 }
 
 func (m *bpfMap[K, V]) BatchDelete(keys []K) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lc.closed() {
+		return ErrDataStructureClosed
+	}
+
 	active := m.getActiveMap()
-	if _, err := active.BatchDelete(keys, nil); err != nil {
-		return err
+	n, err := active.BatchDelete(keys, nil)
+
+	// The kernel processes keys in order and stops at the first failure,
+	// hence the first n keys have been deleted even if an error occured.
+	activeKeys := m.getActiveKeysFromCache()
+	for _, k := range keys[:n] {
+		delete(activeKeys, k)
 	}
-	return nil
+
+	if err != nil {
+		return flaterrors.Join(err, m.setActiveLen(uint32(len(activeKeys))))
+	}
+
+	return m.setActiveLen(uint32(len(activeKeys)))
 }
 
 func (m *bpfMap[K, V]) Set(newMap map[K]V) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lc.closed() {
+		return ErrDataStructureClosed
+	}
+
+	if m.switchoverPending {
+		return ErrSwitchoverPending
+	}
+
 	if err := m.set(newMap); err != nil {
 		return err
 	}
@@ -171,32 +365,46 @@ func (m *bpfMap[K, V]) Set(newMap map[K]V) error {
 	return nil
 }
 
-func (m *bpfMap[K, V]) SetAndDeferSwitchover(newMap map[K]V) (func(), error) {
+func (m *bpfMap[K, V]) SetAndDeferSwitchover(newMap map[K]V) (func() error, func(), error) {
+	sw, err := m.PrepareSwitchover(newMap)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sw.Commit, sw.Abort, nil
+}
+
+func (m *bpfMap[K, V]) PrepareSwitchover(newMap map[K]V) (Switchover, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lc.closed() {
+		return nil, ErrDataStructureClosed
+	}
+
+	if m.switchoverPending {
+		return nil, ErrSwitchoverPending
+	}
+
 	if err := m.set(newMap); err != nil {
 		return nil, err
 	}
 
-	return sync.OnceFunc(func() {
-		var err error
-		for i := range deferableSwitchoverMaxTries {
-			if err = m.switchover(); err != nil {
-				time.Sleep(time.Duration(i) * deferableSwitchoverTimeout)
-				continue
-			}
-			return
-		}
-		slog.Error("cannot perform differable switchover", "err", err.Error())
-		panic("CRITICAL ERROR")
-	}), nil
+	m.switchoverPending = true
+
+	return newSwitchover(m, m.retryPolicy), nil
 }
 
 // set performs at most 2 syscalls.
 func (m *bpfMap[K, V]) set(newMap map[K]V) error {
 	passiveMap := m.getPassiveMap()
-	activeKeys := m.getActiveKeysFromCache()
+	// The entries to delete are the ones currently stored in the PASSIVE
+	// map, which may differ from the ACTIVE map, e.g. after BatchUpdate or
+	// BatchDelete.
+	passiveKeys := m.getPassiveKeysFromCache()
 
 	newLen := uint32(len(newMap))
-	oldLen := uint32(len(activeKeys))
+	oldLen := uint32(len(passiveKeys))
 
 	newKeys := make(map[K]struct{}, newLen)
 	oldKeys := make(map[K]struct{}, oldLen)
@@ -204,7 +412,7 @@ func (m *bpfMap[K, V]) set(newMap map[K]V) error {
 	// Because we mutate the oldKeys map below, we must copy
 	// oldKeys in order to avoid side-effects on retry after
 	// an error occured.
-	for k := range activeKeys {
+	for k := range passiveKeys {
 		oldKeys[k] = struct{}{}
 	}
 
@@ -264,6 +472,85 @@ func (m *bpfMap[K, V]) set(newMap map[K]V) error {
 	return nil
 }
 
+// getAll returns all entries of bpfMap.
+func (m *bpfMap[K, V]) getAll(bpfMap *ebpf.Map) (map[K]V, error) {
+	out := make(map[K]V)
+	if err := m.iterate(bpfMap, func(k K, v V) bool {
+		out[k] = v
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// iterate calls yield for each entry of bpfMap until yield returns false.
+// It performs one syscall per batchLookupSize entries.
+func (m *bpfMap[K, V]) iterate(bpfMap *ebpf.Map, yield func(K, V) bool) error {
+	keys := make([]K, batchLookupSize)
+	values := make([]V, batchLookupSize)
+	cursor := new(ebpf.MapBatchCursor)
+
+	for {
+		// -- LOOKUP_BATCH
+		count, err := bpfMap.BatchLookup(cursor, keys, values, nil)
+		for i := range count {
+			if !yield(keys[i], values[i]) {
+				return nil
+			}
+		}
+
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			// the end of the map has been reached.
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// recoverState rebuilds the internal state from the kernel.
+//
+// The length of each map is derived from its keys, hence the `aLen` & `bLen`
+// bpf variables are overwritten if they disagree with the recovered keys.
+func (m *bpfMap[K, V]) recoverState() error {
+	activePointer, err := recoverActivePointer(m.activePointer)
+	if err != nil {
+		return err
+	}
+
+	for _, side := range []struct {
+		bpfMap    *ebpf.Map
+		length    variable
+		keysCache map[K]struct{}
+	}{
+		{bpfMap: m.a, length: m.aLen, keysCache: m.aKeysCache},
+		{bpfMap: m.b, length: m.bLen, keysCache: m.bKeysCache},
+	} {
+		if err := m.iterate(side.bpfMap, func(k K, _ V) bool {
+			side.keysCache[k] = struct{}{}
+			return true
+		}); err != nil {
+			return flaterrors.Join(err, ErrRecoveringState)
+		}
+
+		length, err := recoverLen(side.length)
+		if err != nil {
+			return err
+		}
+
+		if newLen := uint32(len(side.keysCache)); length != newLen {
+			if err := side.length.Set(newLen); err != nil {
+				return flaterrors.Join(err, ErrRecoveringState)
+			}
+		}
+	}
+
+	m.activePointerCache = activePointer
+
+	return nil
+}
+
 func (m *bpfMap[K, V]) switchover() error {
 	newActive := 1 - m.activePointerCache
 	if err := m.activePointer.Set(newActive); err != nil {
@@ -294,16 +581,25 @@ func (m *bpfMap[K, V]) getActiveKeysFromCache() map[K]struct{} {
 	return m.bKeysCache
 }
 
-func (m *bpfMap[K, V]) setPassiveLen(newLen uint32) error {
+func (m *bpfMap[K, V]) getPassiveKeysFromCache() map[K]struct{} {
 	if m.activePointerCache == 0 {
-		if err := m.bLen.Set(newLen); err != nil {
-			return err
-		}
+		return m.bKeysCache
 	}
-	if err := m.aLen.Set(newLen); err != nil {
-		return err
+	return m.aKeysCache
+}
+
+func (m *bpfMap[K, V]) setActiveLen(newLen uint32) error {
+	if m.activePointerCache == 0 {
+		return m.aLen.Set(newLen)
 	}
-	return nil
+	return m.bLen.Set(newLen)
+}
+
+func (m *bpfMap[K, V]) setPassiveLen(newLen uint32) error {
+	if m.activePointerCache == 0 {
+		return m.bLen.Set(newLen)
+	}
+	return m.aLen.Set(newLen)
 }
 
 func (m *bpfMap[K, V]) cachePassiveKeys(newKeys map[K]struct{}) {
@@ -313,3 +609,42 @@ func (m *bpfMap[K, V]) cachePassiveKeys(newKeys map[K]struct{}) {
 	}
 	m.aKeysCache = newKeys
 }
+
+// -------------------------------------------------------------------
+// -- SWITCHOVER MEMBER
+// -------------------------------------------------------------------
+
+var _ switchoverMember = &bpfMap[uint32, any]{}
+
+func (m *bpfMap[K, V]) mutex() *sync.RWMutex {
+	return m.mu
+}
+
+func (m *bpfMap[K, V]) getActivePointer() variable {
+	return m.activePointer
+}
+
+func (m *bpfMap[K, V]) getActivePointerCache() uint8 {
+	return m.activePointerCache
+}
+
+func (m *bpfMap[K, V]) setActivePointerCache(v uint8) {
+	m.activePointerCache = v
+}
+
+func (m *bpfMap[K, V]) isSwitchoverPending() bool {
+	return m.switchoverPending
+}
+
+func (m *bpfMap[K, V]) setSwitchoverPending(v bool) {
+	m.switchoverPending = v
+}
+
+func (m *bpfMap[K, V]) restage() error {
+	activeMap, err := m.getAll(m.getActiveMap())
+	if err != nil {
+		return err
+	}
+
+	return m.set(activeMap)
+}
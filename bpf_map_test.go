@@ -0,0 +1,314 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"errors"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cilium/ebpf"
+)
+
+func TestMapSetWithBatchOperations(t *testing.T) {
+	m := newTestBPFMap(t, nil, nil)
+
+	for i, step := range []struct {
+		name string
+		run  func() error
+		want map[uint32]uint32
+	}{
+		{name: "set", run: func() error { return m.Set(map[uint32]uint32{1: 1}) }, want: map[uint32]uint32{1: 1}},
+		{name: "set disjoint keys", run: func() error { return m.Set(map[uint32]uint32{2: 2}) }, want: map[uint32]uint32{2: 2}},
+		{name: "set disjoint keys again", run: func() error { return m.Set(map[uint32]uint32{3: 3}) }, want: map[uint32]uint32{3: 3}},
+		{name: "batch update", run: func() error { return m.BatchUpdate(map[uint32]uint32{4: 4, 5: 5}) }, want: map[uint32]uint32{3: 3, 4: 4, 5: 5}},
+		{name: "set after batch update", run: func() error { return m.Set(map[uint32]uint32{5: 50, 6: 6}) }, want: map[uint32]uint32{5: 50, 6: 6}},
+		{name: "set overwrites passive keys", run: func() error { return m.Set(map[uint32]uint32{7: 7}) }, want: map[uint32]uint32{7: 7}},
+		{name: "batch delete", run: func() error { return m.BatchDelete([]uint32{7}) }, want: map[uint32]uint32{}},
+		{name: "set after batch delete", run: func() error { return m.Set(map[uint32]uint32{8: 8}) }, want: map[uint32]uint32{8: 8}},
+		{name: "set after batch delete again", run: func() error { return m.Set(map[uint32]uint32{9: 9}) }, want: map[uint32]uint32{9: 9}},
+	} {
+		if err := step.run(); err != nil {
+			t.Fatalf("step %d (%s): %v", i, step.name, err)
+		}
+
+		got, err := m.GetAll()
+		if err != nil {
+			t.Fatalf("step %d (%s): %v", i, step.name, err)
+		}
+
+		if !maps.Equal(got, step.want) {
+			t.Fatalf("step %d (%s): want %v; got %v", i, step.name, step.want, got)
+		}
+
+		assertMapSidesConsistent(t, m)
+	}
+}
+
+// assertMapSidesConsistent asserts the content of both internal maps
+// matches their key cache & their length variable.
+func assertMapSidesConsistent(t *testing.T, m *bpfMap[uint32, uint32]) {
+	t.Helper()
+
+	for _, side := range []struct {
+		name      string
+		bpfMap    *ebpf.Map
+		length    variable
+		keysCache map[uint32]struct{}
+	}{
+		{name: "a", bpfMap: m.a, length: m.aLen, keysCache: m.aKeysCache},
+		{name: "b", bpfMap: m.b, length: m.bLen, keysCache: m.bKeysCache},
+	} {
+		entries, err := m.getAll(side.bpfMap)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		keys := make(map[uint32]struct{}, len(entries))
+		for k := range entries {
+			keys[k] = struct{}{}
+		}
+
+		if !maps.Equal(keys, side.keysCache) {
+			t.Fatalf("map %s: entries %v do not match keys cache %v", side.name, entries, side.keysCache)
+		}
+
+		var length uint32
+		if err := side.length.Get(&length); err != nil {
+			t.Fatal(err)
+		}
+
+		if int(length) != len(entries) {
+			t.Fatalf("map %s: want length %d; got %d", side.name, len(entries), length)
+		}
+	}
+}
+
+func TestMapConcurrentAccess(t *testing.T) {
+	m := newTestBPFMap(t, nil, nil)
+
+	// generationMap returns the entries written by generation gen. It allows
+	// readers to detect a mix of entries written by different generations.
+	generationMap := func(gen uint32) map[uint32]uint32 {
+		out := make(map[uint32]uint32)
+		for k := range gen%8 + 1 {
+			out[k] = gen
+		}
+		return out
+	}
+
+	var (
+		gen atomic.Uint32
+		wg  sync.WaitGroup
+	)
+
+	for range 4 {
+		wg.Add(3)
+
+		// -- Set
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				err := m.Set(generationMap(gen.Add(1)))
+				if err != nil && !errors.Is(err, ErrSwitchoverPending) {
+					t.Error(err)
+				}
+			}
+		}()
+
+		// -- PrepareSwitchover & Commit or Abort
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				sw, err := m.PrepareSwitchover(generationMap(gen.Add(1)))
+				if errors.Is(err, ErrSwitchoverPending) {
+					continue
+				} else if err != nil {
+					t.Error(err)
+					continue
+				}
+
+				if i%2 == 0 {
+					sw.Abort()
+					continue
+				}
+
+				if err := sw.Commit(); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+
+		// -- GetAll & Lookup
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				entries, err := m.GetAll()
+				if err != nil {
+					t.Error(err)
+				}
+
+				if len(entries) > 0 && !maps.Equal(entries, generationMap(entries[0])) {
+					t.Errorf("entries written by different generations: %v", entries)
+				}
+
+				if _, _, err := m.Lookup(0); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assertMapSidesConsistent(t, m)
+}
+
+func TestMapSetWhileSwitchoverPending(t *testing.T) {
+	m := newTestBPFMap(t, nil, nil)
+
+	if err := m.Set(map[uint32]uint32{1: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	sw, err := m.PrepareSwitchover(map[uint32]uint32{2: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Set(map[uint32]uint32{3: 3}); !errors.Is(err, ErrSwitchoverPending) {
+		t.Fatalf("Set: want ErrSwitchoverPending; got %v", err)
+	}
+
+	if _, err := m.PrepareSwitchover(map[uint32]uint32{3: 3}); !errors.Is(err, ErrSwitchoverPending) {
+		t.Fatalf("PrepareSwitchover: want ErrSwitchoverPending; got %v", err)
+	}
+
+	if _, _, err := m.SetAndDeferSwitchover(map[uint32]uint32{3: 3}); !errors.Is(err, ErrSwitchoverPending) {
+		t.Fatalf("SetAndDeferSwitchover: want ErrSwitchoverPending; got %v", err)
+	}
+
+	assertMapEntries(t, m, map[uint32]uint32{1: 1})
+
+	if err := sw.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertMapEntries(t, m, map[uint32]uint32{2: 2})
+
+	if err := m.Set(map[uint32]uint32{3: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertMapEntries(t, m, map[uint32]uint32{3: 3})
+	assertMapSidesConsistent(t, m)
+}
+
+func assertMapEntries(t *testing.T, m *bpfMap[uint32, uint32], want map[uint32]uint32) {
+	t.Helper()
+
+	got, err := m.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !maps.Equal(got, want) {
+		t.Fatalf("want %v; got %v", want, got)
+	}
+}
+
+func TestMapSetAndDeferSwitchover(t *testing.T) {
+	m := newTestBPFMap(t, nil, nil)
+
+	// -- abort releases the pending switchover.
+	commit, abort, err := m.SetAndDeferSwitchover(map[uint32]uint32{1: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	abort()
+
+	if err := m.Set(map[uint32]uint32{2: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := commit(); !errors.Is(err, ErrSwitchoverClosed) {
+		t.Fatalf("want ErrSwitchoverClosed; got %v", err)
+	}
+
+	assertMapEntries(t, m, map[uint32]uint32{2: 2})
+
+	// -- commit performs the switchover.
+	commit, _, err = m.SetAndDeferSwitchover(map[uint32]uint32{3: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertMapEntries(t, m, map[uint32]uint32{3: 3})
+	assertMapSidesConsistent(t, m)
+}
+
+func TestMapIterate(t *testing.T) {
+	m := newTestBPFMap(t, nil, nil)
+
+	want := map[uint32]uint32{1: 10, 2: 20, 3: 30}
+	if err := m.Set(want); err != nil {
+		t.Fatal(err)
+	}
+
+	seq, errFn := m.Iterate()
+
+	// -- the iterator can be ranged over concurrently.
+	wg := &sync.WaitGroup{}
+	results := make([]map[uint32]uint32, 4)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = maps.Collect(seq)
+		}()
+	}
+	wg.Wait()
+
+	for _, got := range results {
+		if !maps.Equal(got, want) {
+			t.Fatalf("want %v; got %v", want, got)
+		}
+	}
+
+	if err := errFn(); err != nil {
+		t.Fatal(err)
+	}
+
+	// -- each iteration reports its own error.
+	if err := m.getActiveMap().Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for range seq {
+		t.Fatal("want no entries from a closed map")
+	}
+
+	if err := errFn(); err == nil {
+		t.Fatal("want an error iterating over a closed map")
+	}
+}
@@ -16,51 +16,352 @@
 package ebpfstruct
 
 import (
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
 
 	"github.com/alexandremahdhaoui/tooling/pkg/flaterrors"
 	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"golang.org/x/sys/unix"
 )
 
 var ErrCreatingNewVariable = errors.New("creating new variable")
 
-// This is only used in order to write tests. No fancy feature.
-// Maybe in the future add support for:
-//   - Get()
-//   - caching & no-cache for Get().
-//   - Set with differable switchover in order to sync switchover
-//     with many bpf data structures.
+// Wraps a bpf variable with a convenient interface for testing.
+//
+// Please use DoubleBufferedVariable to sync the switchover of a variable
+// with many bpf data structures.
+//
+// Notes:
+//   - Variable is thread-safe.
+//   - Update, CompareAndSwap & Add are performed with atomic instructions
+//     on the memory-mapped data section. They require a Variable created
+//     by NewVariableFromDataSection whose value fits in an aligned 8-byte
+//     word, otherwise they return ErrAtomicsNotSupported.
+//   - Concurrent updates performed by the bpf program are never lost as
+//     long as it also uses atomic instructions, e.g. __sync_fetch_and_add.
+//   - GetField & SetField resolve members using the BTF of the variable,
+//     bitfields are not supported. SetField requires a Variable created
+//     by NewVariableFromDataSection, otherwise it returns
+//     ErrFieldWritesNotSupported.
 type Variable[T any] interface {
 	// Done returns a channel that's closed when work done on behalf of this
 	// interface has been gracefully terminated.
 	Done() <-chan struct{}
 
+	// Close closes the channel returned by Done(). Subsequent updates
+	// return ErrDataStructureClosed.
+	//
+	// Closing the doneCh passed to the constructor closes the Variable.
+	// Close can be called multiple times.
+	Close() error
+
+	// Get returns the value of the variable, e.g. a flag or a counter
+	// updated by the bpf program.
+	//
+	// The value is read from the kernel, unless the Variable was created
+	// with the WithCache() option and has been written before by this
+	// process.
+	Get() (T, error)
+
 	// Set the variable.
 	Set(v T) error
+
+	// Update atomically replaces the value of the variable with fn(v),
+	// where v is its current value.
+	//
+	// fn is called again if the variable has been concurrently modified,
+	// e.g. by the bpf program, hence it must be free of side effects.
+	//
+	// Update returns ErrAtomicsNotSupported if the Variable was created by
+	// NewVariable.
+	Update(fn func(T) T) error
+
+	// CompareAndSwap atomically sets the variable to newValue if its
+	// current value is old. It reports whether the swap has been performed.
+	//
+	// Values are compared using their binary representation.
+	// CompareAndSwap returns ErrAtomicsNotSupported if the Variable was
+	// created by NewVariable.
+	CompareAndSwap(old, newValue T) (bool, error)
+
+	// Add atomically adds delta to the variable and returns the new value.
+	//
+	// Add returns ErrNotAnInteger if T is not an integer type, and
+	// ErrAtomicsNotSupported if the Variable was created by NewVariable.
+	Add(delta T) (T, error)
+
+	// GetField writes the value of the member of the variable at path to
+	// out, e.g. "limits.max_conns". out must be a pointer to a value
+	// matching the BTF type of the member.
+	GetField(path string, out any) error
+
+	// SetField writes v to the member of the variable at path, e.g.
+	// "limits.max_conns", leaving the other members untouched. v must
+	// match the BTF type of the member.
+	//
+	// SetField returns ErrFieldWritesNotSupported if the Variable was
+	// created by NewVariable.
+	SetField(path string, v any) error
 }
 
 // doneCh is a channel used to notify the bpf data structures or bpf
 // program has been closed and they can no longer be used.
-func NewVariable[T any](obj *ebpf.Variable, doneCh <-chan struct{}) (Variable[T], error) {
+//
+// Get() can be served from a userspace cache using the WithCache() option.
+//
+// The returned Variable does not support atomic operations nor SetField,
+// please use NewVariableFromDataSection.
+func NewVariable[T any](obj *ebpf.Variable, doneCh <-chan struct{}, opts ...Option) (Variable[T], error) {
 	if obj == nil {
 		return nil, flaterrors.Join(ErrEBPFObjectsMustNotBeNil, ErrCreatingNewVariable)
 	}
 
-	return &bpfVariable[T]{obj: obj}, nil
+	var typ btf.Type
+	if obj.Type() != nil {
+		typ = obj.Type().Type
+	}
+
+	if err := validateType[T]("variable", uint32(obj.Size()), typ); err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewVariable)
+	}
+
+	return newVariable[T](obj, typ, doneCh, opts...), nil
+}
+
+// newVariable creates a Variable[T] from obj, whose BTF type is typ. typ
+// may be nil.
+func newVariable[T any](obj variable, typ btf.Type, doneCh <-chan struct{}, opts ...Option) *bpfVariable[T] {
+	o := newOptions(opts...)
+
+	return &bpfVariable[T]{
+		obj:        obj,
+		typ:        typ,
+		cache:      o.cache,
+		valueCache: *new(T),
+		cached:     false,
+		mu:         &sync.RWMutex{},
+		lc:         newLifecycle(doneCh, o.closer),
+	}
 }
 
 type bpfVariable[T any] struct {
-	obj *ebpf.Variable
-	// doneCh is a channel used to notify the bpf data structures or bpf
-	// program has been closed and they can no longer be used.
-	doneCh <-chan struct{}
+	obj variable
+	// typ is the BTF type of the variable. It may be nil.
+	typ btf.Type
+
+	// cache enables serving Get() from valueCache.
+	cache bool
+	// valueCache is a copy of the value last written. It is valid only if
+	// cached is true.
+	valueCache T
+	cached     bool
+
+	// mu protects all fields above from concurrent access.
+	mu *sync.RWMutex
+	// lc ends when Close() is called or when the doneCh passed to the
+	// constructor is closed.
+	lc *lifecycle
 }
 
 func (bv *bpfVariable[T]) Done() <-chan struct{} {
-	return bv.doneCh
+	return bv.lc.Done()
+}
+
+func (bv *bpfVariable[T]) Close() error {
+	return bv.lc.Close()
+}
+
+// Get implements Variable.
+func (bv *bpfVariable[T]) Get() (T, error) {
+	bv.mu.RLock()
+	defer bv.mu.RUnlock()
+
+	if bv.cache && bv.cached {
+		return bv.valueCache, nil
+	}
+
+	// Values read from the kernel are not cached: they may be updated by
+	// the bpf program at any time.
+	var v T
+	if err := bv.obj.Get(&v); err != nil {
+		return *new(T), err
+	}
+
+	return v, nil
 }
 
 // Set implements BPFVariable.
 func (bv *bpfVariable[T]) Set(v T) error {
-	return bv.obj.Set(v)
+	bv.mu.Lock()
+	defer bv.mu.Unlock()
+
+	if bv.lc.closed() {
+		return ErrDataStructureClosed
+	}
+
+	if err := bv.obj.Set(v); err != nil {
+		return err
+	}
+
+	if bv.cache {
+		bv.valueCache, bv.cached = v, true
+	}
+
+	return nil
+}
+
+// -------------------------------------------------------------------
+// -- VARIABLE
+// -------------------------------------------------------------------
+
+// variable abstracts a bpf variable. It is satisfied by *ebpf.Variable &
+// memoryVariable.
+type variable interface {
+	// Get writes the value of the variable to out.
+	Get(out any) error
+	// Set the value of the variable to in.
+	Set(in any) error
+}
+
+var _ variable = &ebpf.Variable{}
+
+var ErrVariableSizeMismatch = errors.New("value size does not match variable size")
+
+// memoryVariable is a bpf variable located at offset in a memory-mapped
+// data section. All operations are performed in the host's native
+// endianness.
+type memoryVariable struct {
+	mem *memory
+	// mapID is the ID of the data section. It identifies the variable along
+	// with offset, as many handles to the same data section may be loaded,
+	// e.g. from a pin path.
+	mapID  ebpf.MapID
+	offset int
+	size   int
+}
+
+// sameVariable returns true if a & b are the same bpf variable.
+func sameVariable(a, b variable) bool {
+	if a == b {
+		return true
+	}
+
+	ma, ok := a.(*memoryVariable)
+	if !ok {
+		return false
+	}
+
+	mb, ok := b.(*memoryVariable)
+	if !ok {
+		return false
+	}
+
+	return ma.mapID != 0 && ma.mapID == mb.mapID && ma.offset == mb.offset
+}
+
+// Get implements variable.
+func (v *memoryVariable) Get(out any) error {
+	if binary.Size(out) != v.size {
+		return ErrVariableSizeMismatch
+	}
+
+	v.mem.mu.RLock()
+	defer v.mem.mu.RUnlock()
+
+	if v.mem.b == nil {
+		return ErrDataStructureClosed
+	}
+
+	_, err := binary.Decode(v.mem.b[v.offset:v.offset+v.size], binary.NativeEndian, out)
+	return err
+}
+
+// Set implements variable.
+func (v *memoryVariable) Set(in any) error {
+	buf, err := binary.Append(nil, binary.NativeEndian, in)
+	if err != nil {
+		return err
+	}
+
+	if len(buf) != v.size {
+		return ErrVariableSizeMismatch
+	}
+
+	v.mem.mu.RLock()
+	defer v.mem.mu.RUnlock()
+
+	if v.mem.b == nil {
+		return ErrDataStructureClosed
+	}
+
+	copy(v.mem.b[v.offset:], buf)
+	return nil
+}
+
+// -------------------------------------------------------------------
+// -- MEMORY
+// -------------------------------------------------------------------
+
+// memory is a data section memory-mapped by this package. Unlike
+// ebpf.Memory, it exposes the mapped bytes to atomic instructions.
+type memory struct {
+	// mu protects b from being unmapped while it is accessed.
+	mu *sync.RWMutex
+	b  []byte
+}
+
+// mmapDataSection memory-maps m, which must have been created with
+// BPF_F_MMAPABLE.
+//
+// The mapping is released by close(), or when the returned memory is
+// garbage collected.
+func mmapDataSection(m *ebpf.Map) (*memory, error) {
+	if m.Flags()&unix.BPF_F_MMAPABLE == 0 {
+		return nil, ErrDataSectionNotMmapable
+	}
+
+	// Array values are laid out on 8-byte boundaries and the kernel maps
+	// whole pages.
+	pageSize := os.Getpagesize()
+	size := int((m.ValueSize()+7)&^7) * int(m.MaxEntries())
+	size = (size + pageSize - 1) &^ (pageSize - 1)
+
+	b, err := unix.Mmap(m.FD(), 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("mmap data section: %w", err)
+	}
+
+	mem := &memory{mu: &sync.RWMutex{}, b: b}
+	runtime.SetFinalizer(mem, (*memory).close)
+
+	return mem, nil
+}
+
+// variable returns the variable of size bytes located at offset.
+func (mem *memory) variable(mapID ebpf.MapID, offset, size int) (*memoryVariable, error) {
+	if offset < 0 || size < 0 || offset+size > len(mem.b) {
+		return nil, fmt.Errorf("variable at offset %d(+%d) is out of bounds", offset, size)
+	}
+
+	return &memoryVariable{mem: mem, mapID: mapID, offset: offset, size: size}, nil
+}
+
+// close unmaps the data section. Subsequent accesses return
+// ErrDataStructureClosed.
+func (mem *memory) close() error {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	if mem.b == nil {
+		return nil
+	}
+
+	err := unix.Munmap(mem.b)
+	mem.b = nil
+	return err
 }
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"testing"
+)
+
+func TestVariableGetWithCache(t *testing.T) {
+	obj := newTestVariable(t, 4)
+	bv := newTestBPFVariable(t, obj, nil, WithCache())
+
+	// -- values written by the bpf program are read from the kernel until
+	//    this process writes the variable.
+	for _, want := range []uint32{1, 2} {
+		if err := obj.Set(want); err != nil {
+			t.Fatal(err)
+		}
+
+		assertVariable(t, bv, want)
+	}
+
+	// -- values written by this process are served from the cache.
+	if err := bv.Set(3); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := obj.Set(uint32(4)); err != nil {
+		t.Fatal(err)
+	}
+
+	assertVariable(t, bv, 3)
+}
+
+func TestVariableGetWithoutCache(t *testing.T) {
+	obj := newTestVariable(t, 4)
+	bv := newTestBPFVariable(t, obj, nil)
+
+	if err := bv.Set(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := obj.Set(uint32(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	assertVariable(t, bv, 2)
+}
+
+func assertVariable(t *testing.T, bv *bpfVariable[uint32], want uint32) {
+	t.Helper()
+
+	got, err := bv.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != want {
+		t.Fatalf("want %d; got %d", want, got)
+	}
+}
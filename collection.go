@@ -0,0 +1,128 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alexandremahdhaoui/tooling/pkg/flaterrors"
+	"github.com/cilium/ebpf"
+)
+
+var ErrMissingCollectionObjects = errors.New("missing objects in collection")
+
+// -------------------------------------------------------------------
+// -- COLLECTION
+// -------------------------------------------------------------------
+
+// ArrayFromCollection creates an Array[T] from the maps and variables of
+// coll named after base, e.g. with the DefaultNaming and base "backends":
+//   - maps: backends_a & backends_b.
+//   - variables: backends_a_len, backends_b_len & active_pointer.
+//
+// The naming scheme can be configured using the WithNaming() option.
+// ArrayFromCollection returns ErrMissingCollectionObjects listing all objects
+// that cannot be found in coll.
+//
+// doneCh is a channel used to notify the bpf data structures or bpf
+// program has been closed and they can no longer be used.
+func ArrayFromCollection[T any](
+	coll *ebpf.Collection,
+	base string,
+	doneCh <-chan struct{},
+	opts ...Option,
+) (Array[T], error) {
+	objs, err := lookupCollectionObjects(coll, base, newOptions(opts...))
+	if err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewArray)
+	}
+
+	return NewArray[T](objs.a, objs.b, objs.aLen, objs.bLen, objs.activePointer, doneCh, opts...)
+}
+
+// MapFromCollection creates a Map[K,V] from the maps and variables of coll
+// named after base, e.g. with the DefaultNaming and base "lookup_table":
+//   - maps: lookup_table_a & lookup_table_b.
+//   - variables: lookup_table_a_len, lookup_table_b_len & active_pointer.
+//
+// The naming scheme can be configured using the WithNaming() option.
+// MapFromCollection returns ErrMissingCollectionObjects listing all objects
+// that cannot be found in coll.
+//
+// doneCh is a channel used to notify the bpf data structures or bpf
+// program has been closed and they can no longer be used.
+func MapFromCollection[K comparable, V any](
+	coll *ebpf.Collection,
+	base string,
+	doneCh <-chan struct{},
+	opts ...Option,
+) (Map[K, V], error) {
+	objs, err := lookupCollectionObjects(coll, base, newOptions(opts...))
+	if err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewMap)
+	}
+
+	return NewMap[K, V](objs.a, objs.b, objs.aLen, objs.bLen, objs.activePointer, doneCh, opts...)
+}
+
+type collectionObjects struct {
+	a, b          *ebpf.Map
+	aLen, bLen    *ebpf.Variable
+	activePointer *ebpf.Variable
+}
+
+func lookupCollectionObjects(coll *ebpf.Collection, base string, o options) (*collectionObjects, error) {
+	if coll == nil {
+		return nil, ErrEBPFObjectsMustNotBeNil
+	}
+
+	n := o.naming.resolve(base)
+	objs := &collectionObjects{}
+	missing := make([]string, 0)
+
+	for _, m := range []struct {
+		out  **ebpf.Map
+		name string
+	}{
+		{out: &objs.a, name: n.a},
+		{out: &objs.b, name: n.b},
+	} {
+		if *m.out = coll.Maps[m.name]; *m.out == nil {
+			missing = append(missing, fmt.Sprintf("map %q", m.name))
+		}
+	}
+
+	for _, v := range []struct {
+		out  **ebpf.Variable
+		name string
+	}{
+		{out: &objs.aLen, name: n.aLen},
+		{out: &objs.bLen, name: n.bLen},
+		{out: &objs.activePointer, name: n.activePointer},
+	} {
+		if *v.out = coll.Variables[v.name]; *v.out == nil {
+			missing = append(missing, fmt.Sprintf("variable %q", v.name))
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMissingCollectionObjects, strings.Join(missing, ", "))
+	}
+
+	return objs, nil
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"context"
+	"log/slog"
+)
+
+// DefaultBatchSize is the default maximum number of records in a batch
+// delivered by FIFO.SubscribeBatch().
+const DefaultBatchSize = 64
+
+// -------------------------------------------------------------------
+// -- BATCH
+// -------------------------------------------------------------------
+
+// WithBatchSize overrides the DefaultBatchSize, i.e. the maximum number of
+// records in a batch delivered by FIFO.SubscribeBatch(). Values lower than
+// 1 are treated as 1.
+func WithBatchSize(size int) Option {
+	return func(o *options) {
+		o.batchSize = max(size, 1)
+	}
+}
+
+func (f *bpfFifo[T]) SubscribeBatch(ctx context.Context) (<-chan []T, error) {
+	ch := make(chan []T, f.bufferSize)
+	batch := f.getBatch()
+
+	// emit & flush are called while holding the subscriber's lock.
+	flush := func() bool {
+		if len(batch) == 0 {
+			return true
+		}
+		ok := deliver(ch, batch, f.stopCh, f.subscriberPolicy(FIFOResult[T]{}), f.dropped)
+		batch = f.getBatch()
+		return ok
+	}
+
+	emit := func(res FIFOResult[T]) bool {
+		if res.Err != nil {
+			slog.ErrorContext(
+				ctx,
+				"an error occured reading from bpf ring buffer",
+				"err",
+				res.Err.Error(),
+			)
+			if IsTerminalFIFOError(res.Err) {
+				return flush()
+			}
+			return true
+		}
+
+		batch = append(batch, res.Value)
+		if len(batch) < f.batchSize {
+			return true
+		}
+		return flush()
+	}
+
+	sub := newFIFOSubscriber(emit, flush, func() { close(ch) })
+	if err := f.subscribe(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	return ch, nil
+}
+
+func (f *bpfFifo[T]) RecycleBatch(batch []T) {
+	// Clear the batch to release references held by T, if any.
+	clear(batch)
+
+	select {
+	case f.batchPool <- batch[:0]:
+	default:
+	}
+}
+
+// getBatch returns an empty batch from the pool or allocates a new one.
+func (f *bpfFifo[T]) getBatch() []T {
+	select {
+	case batch := <-f.batchPool:
+		return batch
+	default:
+		return make([]T, 0, f.batchSize)
+	}
+}
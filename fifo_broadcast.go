@@ -0,0 +1,197 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"slices"
+	"sync"
+)
+
+// DefaultBroadcastBufferSize is the capacity of the subscriber channels of
+// a FIFO created with the WithBroadcast() option, unless WithBufferSize()
+// configures a non-zero size.
+const DefaultBroadcastBufferSize = 64
+
+// -------------------------------------------------------------------
+// -- BROADCAST
+// -------------------------------------------------------------------
+
+// WithBroadcast allows a FIFO to be subscribed to multiple times. Each
+// record is sent to every subscriber.
+//
+// Each subscriber gets its own channel of capacity configured with
+// WithBufferSize(). Subscribers unsubscribe by cancelling the context passed
+// to FIFO.Subscribe() or FIFO.SubscribeWithErrors(), which closes their
+// channel without closing the FIFO.
+//
+// A slow subscriber never blocks the others: the OverflowBlock policy is
+// treated as OverflowDropNewest. Subscriber channels are therefore never
+// unbuffered, which would drop almost every record: a buffer size of 0
+// is replaced with DefaultBroadcastBufferSize.
+func WithBroadcast() Option {
+	return func(o *options) {
+		o.broadcast = true
+	}
+}
+
+// fifoSubscriber is a subscriber of a FIFO. Its channel is closed exactly
+// once, either when it unsubscribes or when the FIFO is closed.
+type fifoSubscriber[T any] struct {
+	mu     *sync.Mutex
+	closed bool
+	// emit delivers a record or an error to the subscriber channel. It
+	// returns false if it could not be delivered because the FIFO is
+	// being closed.
+	emit func(FIFOResult[T]) bool
+	// flush delivers records buffered by emit, if any. It is called when
+	// no more records are pending in the bpf map. It may be nil.
+	flush   func() bool
+	closeCh func()
+}
+
+func newFIFOSubscriber[T any](
+	emit func(FIFOResult[T]) bool,
+	flush func() bool,
+	closeCh func(),
+) *fifoSubscriber[T] {
+	return &fifoSubscriber[T]{
+		mu:      &sync.Mutex{},
+		closed:  false,
+		emit:    emit,
+		flush:   flush,
+		closeCh: closeCh,
+	}
+}
+
+// send returns false if the subscriber is closed or if res could not be
+// delivered.
+func (s *fifoSubscriber[T]) send(res FIFOResult[T]) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	return s.emit(res)
+}
+
+// sendFlush returns false if the subscriber is closed or if buffered
+// records could not be delivered.
+func (s *fifoSubscriber[T]) sendFlush() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	if s.flush == nil {
+		return true
+	}
+	return s.flush()
+}
+
+func (s *fifoSubscriber[T]) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.closeCh()
+}
+
+// publish sends res to all subscribers. It returns false if the reader
+// must stop, i.e. if the only subscriber of a non-broadcasting FIFO could
+// not receive res.
+func (f *bpfFifo[T]) publish(res FIFOResult[T]) bool {
+	return f.forEachSubscriber(func(sub *fifoSubscriber[T]) bool {
+		return sub.send(res)
+	})
+}
+
+// flush delivers the records buffered by the subscribers. It returns false
+// if the reader must stop.
+func (f *bpfFifo[T]) flush() bool {
+	return f.forEachSubscriber((*fifoSubscriber[T]).sendFlush)
+}
+
+// forEachSubscriber calls send for each subscriber. Subscribers for which
+// send returns false are unsubscribed in broadcast mode; otherwise
+// forEachSubscriber returns false.
+func (f *bpfFifo[T]) forEachSubscriber(send func(*fifoSubscriber[T]) bool) bool {
+	f.mu.Lock()
+	// f.subscribers is copied on write, it does not need to be cloned.
+	subscribers := f.subscribers
+	f.mu.Unlock()
+
+	for _, sub := range subscribers {
+		if send(sub) {
+			continue
+		}
+		if !f.broadcast {
+			return false
+		}
+		f.unsubscribe(sub)
+	}
+
+	return true
+}
+
+// unsubscribeOnSignal unsubscribes sub when signal is closed. It returns
+// early if the FIFO is closed.
+func (f *bpfFifo[T]) unsubscribeOnSignal(signal <-chan struct{}, sub *fifoSubscriber[T]) {
+	select {
+	case <-signal:
+		f.unsubscribe(sub)
+	case <-f.stopCh:
+	}
+}
+
+func (f *bpfFifo[T]) unsubscribe(sub *fifoSubscriber[T]) {
+	f.mu.Lock()
+	f.subscribers = slices.DeleteFunc(slices.Clone(f.subscribers), func(s *fifoSubscriber[T]) bool {
+		return s == sub
+	})
+	f.mu.Unlock()
+
+	sub.close()
+}
+
+// closeSubscribers unsubscribes all subscribers.
+func (f *bpfFifo[T]) closeSubscribers() {
+	f.mu.Lock()
+	subscribers := f.subscribers
+	f.subscribers = nil
+	f.mu.Unlock()
+
+	for _, sub := range subscribers {
+		sub.close()
+	}
+}
+
+// subscriberPolicy returns the OverflowPolicy used to deliver res.
+func (f *bpfFifo[T]) subscriberPolicy(res FIFOResult[T]) OverflowPolicy {
+	switch {
+	case f.broadcast && IsTerminalFIFOError(res.Err):
+		// Make room for the terminal error without blocking.
+		return OverflowDropOldest
+	case IsTerminalFIFOError(res.Err):
+		// Terminal errors are never dropped.
+		return OverflowBlock
+	case f.broadcast && f.overflowPolicy == OverflowBlock:
+		return OverflowDropNewest
+	default:
+		return f.overflowPolicy
+	}
+}
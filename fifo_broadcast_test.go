@@ -0,0 +1,81 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestFIFOBroadcastBufferSize(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		opts       []Option
+		wantBuffer int
+	}{
+		{
+			name:       "default",
+			opts:       nil,
+			wantBuffer: DefaultBroadcastBufferSize,
+		},
+		{
+			name:       "unbuffered",
+			opts:       []Option{WithBufferSize(0)},
+			wantBuffer: DefaultBroadcastBufferSize,
+		},
+		{
+			name:       "configured",
+			opts:       []Option{WithBufferSize(16)},
+			wantBuffer: 16,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			const n = 10
+
+			sample := binary.NativeEndian.AppendUint32(nil, 42)
+			opts := append([]Option{WithBroadcast()}, tc.opts...)
+			f := newFIFO(newFakeFIFOReader(sample, n), BinaryDecoder[uint32](), nil, newOptions(opts...))
+			t.Cleanup(func() { _ = f.Close() })
+
+			ch, err := f.Subscribe(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cap(ch) != tc.wantBuffer {
+				t.Fatalf("want buffer size %d; got %d", tc.wantBuffer, cap(ch))
+			}
+
+			// -- records are buffered until the subscriber receives them.
+			select {
+			case <-f.Done():
+			case <-time.After(time.Second):
+				t.Fatal("FIFO is not done")
+			}
+
+			var received int
+			for range ch {
+				received++
+			}
+
+			if received != n {
+				t.Fatalf("want %d records; got %d (dropped %d)", n, received, f.Stats().Dropped)
+			}
+		})
+	}
+}
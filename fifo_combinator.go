@@ -0,0 +1,290 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"context"
+	"sync"
+)
+
+// -------------------------------------------------------------------
+// -- COMBINATORS
+// -------------------------------------------------------------------
+
+// FilterFIFO returns a FIFO[T] delivering only the records of fifo for
+// which pred returns true. Errors are always delivered.
+//
+// The returned FIFO shares the lifecycle of fifo: Stats() and Close() are
+// delegated to fifo, and Done() is closed once fifo is done and all
+// records have been forwarded.
+func FilterFIFO[T any](fifo FIFO[T], pred func(T) bool) FIFO[T] {
+	return newDerivedFIFO(fifo, func(v T) (T, bool) {
+		return v, pred(v)
+	})
+}
+
+// MapFIFO returns a FIFO[U] delivering the records of fifo transformed by
+// fn. Errors are always delivered.
+//
+// The returned FIFO shares the lifecycle of fifo: Stats() and Close() are
+// delegated to fifo, and Done() is closed once fifo is done and all
+// records have been forwarded.
+func MapFIFO[T, U any](fifo FIFO[T], fn func(T) U) FIFO[U] {
+	return newDerivedFIFO(fifo, func(v T) (U, bool) {
+		return fn(v), true
+	})
+}
+
+// derivedFIFO forwards the records of a parent FIFO[T] converted to U.
+// Records for which convert returns false are dropped.
+type derivedFIFO[T, U any] struct {
+	parent  FIFO[T]
+	convert func(T) (U, bool)
+
+	mu *sync.Mutex
+	// forwarders is the number of running forwarding goroutines.
+	forwarders int
+	parentDone bool
+	// batchPool holds batches recycled with RecycleBatch().
+	batchPool chan []U
+
+	// stopCh is closed by Close(). It interrupts the forwarding goroutines,
+	// which may otherwise block on a subscriber that is not draining its
+	// channel.
+	stopCh   chan struct{}
+	stopOnce *sync.Once
+
+	doneCh   chan struct{}
+	doneOnce *sync.Once
+}
+
+func newDerivedFIFO[T, U any](parent FIFO[T], convert func(T) (U, bool)) *derivedFIFO[T, U] {
+	f := &derivedFIFO[T, U]{
+		parent:     parent,
+		convert:    convert,
+		mu:         &sync.Mutex{},
+		forwarders: 0,
+		parentDone: false,
+		batchPool:  make(chan []U, 2),
+		stopCh:     make(chan struct{}),
+		stopOnce:   &sync.Once{},
+		doneCh:     make(chan struct{}),
+		doneOnce:   &sync.Once{},
+	}
+
+	go func() {
+		<-parent.Done()
+		f.mu.Lock()
+		f.parentDone = true
+		f.mu.Unlock()
+		f.closeIfDone()
+	}()
+
+	return f
+}
+
+func (f *derivedFIFO[T, U]) Done() <-chan struct{} {
+	return f.doneCh
+}
+
+func (f *derivedFIFO[T, U]) Subscribe(ctx context.Context) (<-chan U, error) {
+	f.acquire()
+
+	in, err := f.parent.Subscribe(ctx)
+	if err != nil {
+		f.release()
+		return nil, err
+	}
+
+	out := make(chan U, cap(in))
+	go func() {
+		defer f.release()
+		defer close(out)
+
+		forward(ctx, f.stopCh, in, out, func(v T, send func(U) bool) bool {
+			if u, ok := f.convert(v); ok {
+				return send(u)
+			}
+			return true
+		})
+	}()
+
+	return out, nil
+}
+
+func (f *derivedFIFO[T, U]) SubscribeWithErrors(ctx context.Context) (<-chan FIFOResult[U], error) {
+	f.acquire()
+
+	in, err := f.parent.SubscribeWithErrors(ctx)
+	if err != nil {
+		f.release()
+		return nil, err
+	}
+
+	out := make(chan FIFOResult[U], cap(in))
+	go func() {
+		defer f.release()
+		defer close(out)
+
+		forward(ctx, f.stopCh, in, out, func(res FIFOResult[T], send func(FIFOResult[U]) bool) bool {
+			if res.Err != nil {
+				return send(FIFOResult[U]{Err: res.Err, CPU: res.CPU})
+			}
+			if u, ok := f.convert(res.Value); ok {
+				return send(FIFOResult[U]{Value: u, CPU: res.CPU})
+			}
+			return true
+		})
+	}()
+
+	return out, nil
+}
+
+func (f *derivedFIFO[T, U]) SubscribeBatch(ctx context.Context) (<-chan []U, error) {
+	f.acquire()
+
+	in, err := f.parent.SubscribeBatch(ctx)
+	if err != nil {
+		f.release()
+		return nil, err
+	}
+
+	out := make(chan []U, cap(in))
+	go func() {
+		defer f.release()
+		defer close(out)
+
+		forward(ctx, f.stopCh, in, out, func(batch []T, send func([]U) bool) bool {
+			converted := f.getBatch()
+			for _, v := range batch {
+				if u, ok := f.convert(v); ok {
+					converted = append(converted, u)
+				}
+			}
+			f.parent.RecycleBatch(batch)
+
+			if len(converted) == 0 {
+				f.RecycleBatch(converted)
+				return true
+			}
+			return send(converted)
+		})
+	}()
+
+	return out, nil
+}
+
+func (f *derivedFIFO[T, U]) RecycleBatch(batch []U) {
+	// Clear the batch to release references held by U, if any.
+	clear(batch)
+
+	select {
+	case f.batchPool <- batch[:0]:
+	default:
+	}
+}
+
+func (f *derivedFIFO[T, U]) Stats() FIFOStats {
+	return f.parent.Stats()
+}
+
+// Close closes the parent FIFO and waits until the channel returned by
+// Done() is closed. Records that have not been forwarded yet are dropped.
+func (f *derivedFIFO[T, U]) Close() error {
+	f.stopOnce.Do(func() { close(f.stopCh) })
+
+	if err := f.parent.Close(); err != nil {
+		return err
+	}
+	<-f.doneCh
+	return nil
+}
+
+// getBatch returns an empty batch from the pool or allocates a new one.
+func (f *derivedFIFO[T, U]) getBatch() []U {
+	select {
+	case batch := <-f.batchPool:
+		return batch
+	default:
+		return make([]U, 0)
+	}
+}
+
+// acquire registers a forwarding goroutine.
+func (f *derivedFIFO[T, U]) acquire() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.forwarders++
+}
+
+// release unregisters a forwarding goroutine.
+func (f *derivedFIFO[T, U]) release() {
+	f.mu.Lock()
+	f.forwarders--
+	f.mu.Unlock()
+	f.closeIfDone()
+}
+
+// closeIfDone closes f.doneCh if the parent FIFO is done and no forwarding
+// goroutine is running anymore.
+func (f *derivedFIFO[T, U]) closeIfDone() {
+	f.mu.Lock()
+	done := f.parentDone && f.forwarders == 0
+	f.mu.Unlock()
+
+	if done {
+		f.doneOnce.Do(func() { close(f.doneCh) })
+	}
+}
+
+// forward passes the values received from in to handle until in is
+// closed, ctx is done, stop is closed or handle returns false. handle sends
+// converted values to out using send.
+//
+// forward does not stop when the parent FIFO is done: the parent closes in
+// once all its records have been delivered, hence no record is dropped
+// unless stop is closed.
+func forward[In, Out any](
+	ctx context.Context,
+	stop <-chan struct{},
+	in <-chan In,
+	out chan<- Out,
+	handle func(v In, send func(Out) bool) bool,
+) {
+	send := func(v Out) bool {
+		select {
+		case out <- v:
+			return true
+		case <-ctx.Done():
+			return false
+		case <-stop:
+			return false
+		}
+	}
+
+	for {
+		select {
+		case v, ok := <-in:
+			if !ok || !handle(v, send) {
+				return
+			}
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"testing"
+	"time"
+)
+
+// doneFIFO is a FIFO[int] which is done before its records have been
+// delivered.
+type doneFIFO struct {
+	FIFO[int]
+	n int
+}
+
+func (f doneFIFO) Done() <-chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+func (f doneFIFO) Subscribe(ctx context.Context) (<-chan int, error) {
+	ch := make(chan int)
+	go func() {
+		defer close(ch)
+		for i := range f.n {
+			select {
+			case ch <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func TestMapFIFOForwardsRecordsAfterParentIsDone(t *testing.T) {
+	const n = 100
+
+	f := MapFIFO[int](doneFIFO{n: n}, func(v int) int { return v * 2 })
+
+	ch, err := f.Subscribe(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := 0
+	for v := range ch {
+		if v != i*2 {
+			t.Fatalf("want %d; got %d", i*2, v)
+		}
+		i++
+	}
+
+	if i != n {
+		t.Fatalf("want %d records; got %d", n, i)
+	}
+
+	<-f.Done()
+}
+
+func TestFilterFIFOStopsOnContextDone(t *testing.T) {
+	f := FilterFIFO[int](doneFIFO{n: 100}, func(v int) bool { return v%2 == 0 })
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch, err := f.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := <-ch; v != 0 {
+		t.Fatalf("want 0; got %d", v)
+	}
+
+	cancel()
+
+	for range ch {
+	}
+
+	<-f.Done()
+}
+
+func TestMapFIFOCloseWithSubscriberNotDraining(t *testing.T) {
+	sample, err := binary.Append(nil, binary.NativeEndian, uint32(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent := newFIFO(newFakeFIFOReader(sample, 10), BinaryDecoder[uint32](), nil, newOptions())
+	// received is closed once a record is about to be sent to the
+	// subscriber, which never receives it.
+	received := make(chan struct{})
+	receivedOnce := &sync.Once{}
+	f := MapFIFO[uint32](parent, func(v uint32) uint64 {
+		receivedOnce.Do(func() { close(received) })
+		return uint64(v)
+	})
+
+	if _, err := f.Subscribe(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	<-received
+
+	closed := make(chan error)
+	go func() { closed <- f.Close() }()
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close() did not return")
+	}
+
+	assertDone(t, f)
+}
@@ -0,0 +1,231 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+var (
+	ErrDecoderTypeMismatch = errors.New("decoder does not decode the fifo type")
+	ErrShortRecord         = errors.New("record is too short")
+)
+
+// -------------------------------------------------------------------
+// -- DECODER
+// -------------------------------------------------------------------
+
+// Decoder[T] decodes raw records read from a bpf map into T.
+//
+// The raw record must not be retained after Decode returns.
+type Decoder[T any] interface {
+	Decode(raw []byte, v *T) error
+}
+
+// DecoderFunc[T] is a function implementing Decoder[T].
+type DecoderFunc[T any] func(raw []byte, v *T) error
+
+func (f DecoderFunc[T]) Decode(raw []byte, v *T) error {
+	return f(raw, v)
+}
+
+// WithDecoder overrides the BinaryDecoder used by a FIFO[T] to decode
+// records. The FIFO does not require T to have a fixed size when a decoder
+// is provided.
+//
+// The decoder must be a Decoder[T] where T is the type parameter of the
+// FIFO; otherwise the FIFO constructor returns ErrDecoderTypeMismatch.
+func WithDecoder[T any](decoder Decoder[T]) Option {
+	return func(o *options) {
+		o.decoder = decoder
+	}
+}
+
+// decoderValidator is implemented by decoders that can only decode some
+// types. validate is called once by the FIFO constructors.
+type decoderValidator interface {
+	validate() error
+}
+
+// newDecoder returns the decoder configured in o or the BinaryDecoder.
+func newDecoder[T any](object string, o options) (Decoder[T], error) {
+	if o.decoder == nil {
+		if err := validateFixedSize[T](object); err != nil {
+			return nil, err
+		}
+		return BinaryDecoder[T](), nil
+	}
+
+	decoder, ok := o.decoder.(Decoder[T])
+	if !ok {
+		return nil, fmt.Errorf("%w: want Decoder[%s]; got %T",
+			ErrDecoderTypeMismatch, reflect.TypeFor[T](), o.decoder)
+	}
+
+	if v, ok := decoder.(decoderValidator); ok {
+		if err := v.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return decoder, nil
+}
+
+// -------------------------------------------------------------------
+// -- BUILT-IN DECODERS
+// -------------------------------------------------------------------
+
+// BinaryDecoder[T] decodes records using encoding/binary in native
+// endianness. It is the default decoder of a FIFO[T].
+func BinaryDecoder[T any]() Decoder[T] {
+	return DecoderFunc[T](func(raw []byte, v *T) error {
+		_, err := binary.Decode(raw, binary.NativeEndian, v)
+		return err
+	})
+}
+
+// UnmarshalerDecoder[T] decodes records by calling the UnmarshalBinary
+// method of *T. *T must implement encoding.BinaryUnmarshaler.
+func UnmarshalerDecoder[T any]() Decoder[T] {
+	return unmarshalerDecoder[T]{}
+}
+
+type unmarshalerDecoder[T any] struct{}
+
+func (unmarshalerDecoder[T]) Decode(raw []byte, v *T) error {
+	return any(v).(encoding.BinaryUnmarshaler).UnmarshalBinary(raw)
+}
+
+func (unmarshalerDecoder[T]) validate() error {
+	if _, ok := any(new(T)).(encoding.BinaryUnmarshaler); !ok {
+		return &TypeMismatchError{
+			Object: "decoder",
+			GoType: reflect.TypeFor[T]().String(),
+			Reason: "pointer type must implement encoding.BinaryUnmarshaler",
+		}
+	}
+	return nil
+}
+
+// UnsafeDecoder[T] copies records into T without reflection, i.e. it casts
+// the memory of T to a byte slice.
+//
+// T must be a plain-old-data type, i.e. it must not contain pointers,
+// slices, maps, strings, channels, functions or interfaces, and its Go
+// memory layout, including padding, must match the layout of the bpf
+// record. Records shorter than T are rejected; trailing bytes are ignored.
+func UnsafeDecoder[T any]() Decoder[T] {
+	return unsafeDecoder[T]{}
+}
+
+type unsafeDecoder[T any] struct{}
+
+func (unsafeDecoder[T]) Decode(raw []byte, v *T) error {
+	size := int(unsafe.Sizeof(*v))
+	if len(raw) < size {
+		return fmt.Errorf("%w: want at least %d bytes; got %d bytes", ErrShortRecord, size, len(raw))
+	}
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(v)), size), raw)
+	return nil
+}
+
+func (unsafeDecoder[T]) validate() error {
+	if typ := reflect.TypeFor[T](); !isPlainOldData(typ) {
+		return &TypeMismatchError{
+			Object: "decoder",
+			GoType: typ.String(),
+			Reason: "type must be plain-old-data",
+		}
+	}
+	return nil
+}
+
+// isPlainOldData returns true if typ does not contain pointers or
+// reference types.
+func isPlainOldData(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Array:
+		return isPlainOldData(typ.Elem())
+	case reflect.Struct:
+		for i := range typ.NumField() {
+			if !isPlainOldData(typ.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// LengthPrefixed[H] is a record made of a fixed-size header followed by a
+// variable-length payload, e.g. a trailing string or a packet snippet.
+type LengthPrefixed[H any] struct {
+	Header  H
+	Payload []byte
+}
+
+// LengthPrefixedDecoder[H] decodes records laid out as follows, in native
+// endianness and without padding:
+//
+//	struct {
+//	    H     header;
+//	    __u32 len;
+//	    __u8  payload[len];
+//	};
+//
+// H is decoded using encoding/binary and must have a fixed size. The
+// payload is copied.
+func LengthPrefixedDecoder[H any]() Decoder[LengthPrefixed[H]] {
+	return lengthPrefixedDecoder[H]{}
+}
+
+type lengthPrefixedDecoder[H any] struct{}
+
+func (lengthPrefixedDecoder[H]) Decode(raw []byte, v *LengthPrefixed[H]) error {
+	n, err := binary.Decode(raw, binary.NativeEndian, &v.Header)
+	if err != nil {
+		return err
+	}
+	raw = raw[n:]
+
+	if len(raw) < 4 {
+		return fmt.Errorf("%w: missing payload length", ErrShortRecord)
+	}
+	length := binary.NativeEndian.Uint32(raw)
+	raw = raw[4:]
+
+	if uint64(len(raw)) < uint64(length) {
+		return fmt.Errorf("%w: want payload of %d bytes; got %d bytes", ErrShortRecord, length, len(raw))
+	}
+
+	v.Payload = append(v.Payload[:0], raw[:length]...)
+	return nil
+}
+
+func (lengthPrefixedDecoder[H]) validate() error {
+	return validateFixedSize[H]("decoder: header")
+}
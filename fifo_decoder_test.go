@@ -0,0 +1,264 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testDecoderEvent struct {
+	ID    uint32
+	Flags uint16
+	Port  uint16
+}
+
+// testUnmarshaler decodes the record as a string.
+type testUnmarshaler struct {
+	s string
+}
+
+var errEmptyRecord = errors.New("empty record")
+
+func (u *testUnmarshaler) UnmarshalBinary(raw []byte) error {
+	if len(raw) == 0 {
+		return errEmptyRecord
+	}
+	u.s = string(raw)
+	return nil
+}
+
+func TestDecoders(t *testing.T) {
+	event := testDecoderEvent{ID: 1, Flags: 2, Port: 3}
+	rawEvent, err := binary.Append(nil, binary.NativeEndian, event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// lengthPrefixed encodes a LengthPrefixed[uint32] record, truncated to
+	// size bytes if size is not negative.
+	lengthPrefixed := func(header, length uint32, payload string, size int) []byte {
+		raw := binary.NativeEndian.AppendUint32(nil, header)
+		raw = binary.NativeEndian.AppendUint32(raw, length)
+		raw = append(raw, payload...)
+		if size >= 0 {
+			raw = raw[:size]
+		}
+		return raw
+	}
+
+	decode := func(decoder Decoder[testDecoderEvent], raw []byte) (any, error) {
+		var v testDecoderEvent
+		err := decoder.Decode(raw, &v)
+		return v, err
+	}
+
+	decodeUnmarshaler := func(raw []byte) (any, error) {
+		var v testUnmarshaler
+		err := UnmarshalerDecoder[testUnmarshaler]().Decode(raw, &v)
+		return v, err
+	}
+
+	decodeLengthPrefixed := func(raw []byte) (any, error) {
+		var v LengthPrefixed[uint32]
+		err := LengthPrefixedDecoder[uint32]().Decode(raw, &v)
+		return v, err
+	}
+
+	for _, tc := range []struct {
+		name   string
+		decode func() (any, error)
+		// wantErr is matched if wantFails is true. A nil wantErr matches
+		// any error.
+		wantErr   error
+		wantFails bool
+		want      any
+	}{
+		{
+			name:   "BinaryDecoder",
+			decode: func() (any, error) { return decode(BinaryDecoder[testDecoderEvent](), rawEvent) },
+			want:   event,
+		},
+		{
+			name:      "BinaryDecoder: short record",
+			decode:    func() (any, error) { return decode(BinaryDecoder[testDecoderEvent](), rawEvent[:7]) },
+			wantFails: true,
+		},
+		{
+			name:   "UnmarshalerDecoder",
+			decode: func() (any, error) { return decodeUnmarshaler([]byte("abc")) },
+			want:   testUnmarshaler{s: "abc"},
+		},
+		{
+			name:      "UnmarshalerDecoder: UnmarshalBinary fails",
+			decode:    func() (any, error) { return decodeUnmarshaler(nil) },
+			wantErr:   errEmptyRecord,
+			wantFails: true,
+		},
+		{
+			name:   "UnsafeDecoder",
+			decode: func() (any, error) { return decode(UnsafeDecoder[testDecoderEvent](), rawEvent) },
+			want:   event,
+		},
+		{
+			name: "UnsafeDecoder: trailing bytes are ignored",
+			decode: func() (any, error) {
+				return decode(UnsafeDecoder[testDecoderEvent](), append(rawEvent[:8:8], 0xff))
+			},
+			want: event,
+		},
+		{
+			name:      "UnsafeDecoder: short record",
+			decode:    func() (any, error) { return decode(UnsafeDecoder[testDecoderEvent](), rawEvent[:7]) },
+			wantErr:   ErrShortRecord,
+			wantFails: true,
+		},
+		{
+			name:   "LengthPrefixedDecoder",
+			decode: func() (any, error) { return decodeLengthPrefixed(lengthPrefixed(7, 3, "abc", -1)) },
+			want:   LengthPrefixed[uint32]{Header: 7, Payload: []byte("abc")},
+		},
+		{
+			name:   "LengthPrefixedDecoder: empty payload",
+			decode: func() (any, error) { return decodeLengthPrefixed(lengthPrefixed(7, 0, "", -1)) },
+			want:   LengthPrefixed[uint32]{Header: 7, Payload: nil},
+		},
+		{
+			name:   "LengthPrefixedDecoder: trailing bytes are ignored",
+			decode: func() (any, error) { return decodeLengthPrefixed(lengthPrefixed(7, 2, "abc", -1)) },
+			want:   LengthPrefixed[uint32]{Header: 7, Payload: []byte("ab")},
+		},
+		{
+			name:      "LengthPrefixedDecoder: short header",
+			decode:    func() (any, error) { return decodeLengthPrefixed(lengthPrefixed(7, 3, "abc", 2)) },
+			wantFails: true,
+		},
+		{
+			name:      "LengthPrefixedDecoder: missing prefix",
+			decode:    func() (any, error) { return decodeLengthPrefixed(lengthPrefixed(7, 3, "abc", 4)) },
+			wantErr:   ErrShortRecord,
+			wantFails: true,
+		},
+		{
+			name:      "LengthPrefixedDecoder: short prefix",
+			decode:    func() (any, error) { return decodeLengthPrefixed(lengthPrefixed(7, 3, "abc", 6)) },
+			wantErr:   ErrShortRecord,
+			wantFails: true,
+		},
+		{
+			name:      "LengthPrefixedDecoder: truncated payload",
+			decode:    func() (any, error) { return decodeLengthPrefixed(lengthPrefixed(7, 5, "abc", -1)) },
+			wantErr:   ErrShortRecord,
+			wantFails: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.decode()
+
+			if tc.wantFails {
+				if err == nil || (tc.wantErr != nil && !errors.Is(err, tc.wantErr)) {
+					t.Fatalf("want error %v; got %v", tc.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("want %+v; got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLengthPrefixedDecoderCopiesPayload(t *testing.T) {
+	raw := binary.NativeEndian.AppendUint32(nil, 7)
+	raw = binary.NativeEndian.AppendUint32(raw, 3)
+	raw = append(raw, "abc"...)
+
+	var v LengthPrefixed[uint32]
+	if err := LengthPrefixedDecoder[uint32]().Decode(raw, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	// -- the raw record is reused by the FIFO.
+	copy(raw[8:], "xyz")
+
+	if string(v.Payload) != "abc" {
+		t.Fatalf("want payload %q; got %q", "abc", v.Payload)
+	}
+}
+
+func TestNewDecoderValidation(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		newFn   func() error
+		wantErr error
+	}{
+		{
+			name: "UnmarshalerDecoder",
+			newFn: func() error {
+				_, err := newDecoder[testUnmarshaler]("test", newOptions(WithDecoder(UnmarshalerDecoder[testUnmarshaler]())))
+				return err
+			},
+		},
+		{
+			name: "UnmarshalerDecoder: not an unmarshaler",
+			newFn: func() error {
+				_, err := newDecoder[testDecoderEvent]("test", newOptions(WithDecoder(UnmarshalerDecoder[testDecoderEvent]())))
+				return err
+			},
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name: "UnsafeDecoder: not plain-old-data",
+			newFn: func() error {
+				_, err := newDecoder[testUnmarshaler]("test", newOptions(WithDecoder(UnsafeDecoder[testUnmarshaler]())))
+				return err
+			},
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name: "LengthPrefixedDecoder: header without a fixed size",
+			newFn: func() error {
+				_, err := newDecoder[LengthPrefixed[string]]("test", newOptions(WithDecoder(LengthPrefixedDecoder[string]())))
+				return err
+			},
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name: "decoder of another type",
+			newFn: func() error {
+				_, err := newDecoder[testDecoderEvent]("test", newOptions(WithDecoder(BinaryDecoder[uint32]())))
+				return err
+			},
+			wantErr: ErrDecoderTypeMismatch,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.newFn()
+			if tc.wantErr == nil && err != nil {
+				t.Fatal(err)
+			} else if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want %v; got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import "sync/atomic"
+
+// -------------------------------------------------------------------
+// -- OVERFLOW POLICY
+// -------------------------------------------------------------------
+
+// OverflowPolicy configures how a FIFO behaves when a subscriber does not
+// receive records as fast as they are produced, i.e. when the subscriber
+// channel is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits until the subscriber receives the record. While
+	// waiting, records are not read from the bpf ring buffer, which may
+	// overflow.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drops the record that cannot be sent.
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest record buffered in the subscriber
+	// channel to make room for the new record. With an unbuffered channel,
+	// it behaves like OverflowDropNewest.
+	OverflowDropOldest
+)
+
+// FIFOStats reports counters of a FIFO.
+type FIFOStats struct {
+	// Dropped is the number of records dropped in userspace because of the
+	// OverflowPolicy. A batch dropped by FIFO.SubscribeBatch() counts as
+	// one.
+	Dropped uint64
+	// Lost is the number of samples lost by the kernel, e.g. because a perf
+	// buffer was full. It is only reported by FIFOs created with
+	// NewPerfFIFO().
+	Lost uint64
+	// LostPerCPU is the number of samples lost by the kernel per CPU.
+	LostPerCPU map[int]uint64
+}
+
+// WithBufferSize sets the capacity of the channels returned by
+// FIFO.Subscribe() and FIFO.SubscribeWithErrors(). Defaults to 0, i.e.
+// unbuffered, or to DefaultBroadcastBufferSize with the WithBroadcast()
+// option.
+func WithBufferSize(size int) Option {
+	return func(o *options) {
+		o.bufferSize = max(size, 0)
+	}
+}
+
+// WithOverflowPolicy overrides the default OverflowBlock policy of a FIFO.
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(o *options) {
+		o.overflowPolicy = policy
+	}
+}
+
+// deliver sends v to ch according to policy. Dropped records are counted
+// in dropped. It returns false if stopCh is closed before v could be
+// delivered.
+func deliver[V any](
+	ch chan V,
+	v V,
+	stopCh <-chan struct{},
+	policy OverflowPolicy,
+	dropped *atomic.Uint64,
+) bool {
+	if policy == OverflowBlock {
+		select {
+		case ch <- v:
+			return true
+		case <-stopCh:
+			return false
+		}
+	}
+
+	select {
+	case <-stopCh:
+		return false
+	default:
+	}
+
+	for {
+		select {
+		case ch <- v:
+			return true
+		default:
+		}
+
+		if policy == OverflowDropNewest || cap(ch) == 0 {
+			dropped.Add(1)
+			return true
+		}
+
+		// OverflowDropOldest: make room for v. If the subscriber received
+		// the oldest record in the meantime, just try again.
+		select {
+		case <-ch:
+			dropped.Add(1)
+		default:
+		}
+	}
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDeliver(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		policy   OverflowPolicy
+		capacity int
+		buffered []int
+		stopped  bool
+
+		want         bool
+		wantBuffered []int
+		wantDropped  uint64
+	}{
+		{
+			name:         "block: room left",
+			policy:       OverflowBlock,
+			capacity:     2,
+			buffered:     []int{1},
+			want:         true,
+			wantBuffered: []int{1, 3},
+		},
+		{
+			name:         "block: full & stopped",
+			policy:       OverflowBlock,
+			capacity:     2,
+			buffered:     []int{1, 2},
+			stopped:      true,
+			want:         false,
+			wantBuffered: []int{1, 2},
+		},
+		{
+			name:         "block: unbuffered & stopped",
+			policy:       OverflowBlock,
+			capacity:     0,
+			stopped:      true,
+			want:         false,
+			wantBuffered: []int{},
+		},
+		{
+			name:         "drop newest: room left",
+			policy:       OverflowDropNewest,
+			capacity:     2,
+			buffered:     []int{1},
+			want:         true,
+			wantBuffered: []int{1, 3},
+		},
+		{
+			name:         "drop newest: full",
+			policy:       OverflowDropNewest,
+			capacity:     2,
+			buffered:     []int{1, 2},
+			want:         true,
+			wantBuffered: []int{1, 2},
+			wantDropped:  1,
+		},
+		{
+			name:         "drop newest: unbuffered",
+			policy:       OverflowDropNewest,
+			capacity:     0,
+			want:         true,
+			wantBuffered: []int{},
+			wantDropped:  1,
+		},
+		{
+			name:         "drop newest: stopped",
+			policy:       OverflowDropNewest,
+			capacity:     2,
+			stopped:      true,
+			want:         false,
+			wantBuffered: []int{},
+		},
+		{
+			name:         "drop oldest: room left",
+			policy:       OverflowDropOldest,
+			capacity:     2,
+			buffered:     []int{1},
+			want:         true,
+			wantBuffered: []int{1, 3},
+		},
+		{
+			name:         "drop oldest: full",
+			policy:       OverflowDropOldest,
+			capacity:     2,
+			buffered:     []int{1, 2},
+			want:         true,
+			wantBuffered: []int{2, 3},
+			wantDropped:  1,
+		},
+		{
+			// -- behaves like OverflowDropNewest.
+			name:         "drop oldest: unbuffered",
+			policy:       OverflowDropOldest,
+			capacity:     0,
+			want:         true,
+			wantBuffered: []int{},
+			wantDropped:  1,
+		},
+		{
+			name:         "drop oldest: stopped",
+			policy:       OverflowDropOldest,
+			capacity:     2,
+			buffered:     []int{1, 2},
+			stopped:      true,
+			want:         false,
+			wantBuffered: []int{1, 2},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ch := make(chan int, tc.capacity)
+			for _, v := range tc.buffered {
+				ch <- v
+			}
+
+			stopCh := make(chan struct{})
+			if tc.stopped {
+				close(stopCh)
+			}
+
+			dropped := &atomic.Uint64{}
+			if got := deliver(ch, 3, stopCh, tc.policy, dropped); got != tc.want {
+				t.Fatalf("want %t; got %t", tc.want, got)
+			}
+
+			close(ch)
+			buffered := make([]int, 0)
+			for v := range ch {
+				buffered = append(buffered, v)
+			}
+
+			if !slices.Equal(buffered, tc.wantBuffered) {
+				t.Fatalf("want buffered %v; got %v", tc.wantBuffered, buffered)
+			}
+
+			if got := dropped.Load(); got != tc.wantDropped {
+				t.Fatalf("want %d dropped; got %d", tc.wantDropped, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"github.com/alexandremahdhaoui/tooling/pkg/flaterrors"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/perf"
+)
+
+// -------------------------------------------------------------------
+// -- PERF FIFO
+// -------------------------------------------------------------------
+
+// NewPerfFIFO creates a FIFO[T] reading from a BPF_MAP_TYPE_PERF_EVENT_ARRAY.
+//
+// perCPUBuffer is the size in bytes of each per-CPU buffer, see
+// perf.NewReader. Samples lost by the kernel are reported by
+// FIFO.Stats(), and the CPU a record originates from is reported in
+// FIFOResult.CPU.
+//
+// Generics constraints:
+// - T must be a **struct**.
+// - T must not be a pointer.
+// - T must not be an interface.
+//
+// doneCh is a channel used to notify the bpf data structures or bpf
+// program has been closed and they can no longer be used. Closing doneCh
+// closes the FIFO.
+func NewPerfFIFO[T any](
+	perfEventArray *ebpf.Map,
+	perCPUBuffer int,
+	doneCh <-chan struct{},
+	opts ...Option,
+) (FIFO[T], error) {
+	if perfEventArray == nil {
+		return nil, flaterrors.Join(ErrEBPFObjectsMustNotBeNil, ErrCreatingNewFIFO)
+	}
+
+	o := newOptions(opts...)
+
+	decoder, err := newDecoder[T]("perf event array: record", o)
+	if err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewFIFO)
+	}
+
+	rd, err := perf.NewReader(perfEventArray, perCPUBuffer)
+	if err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewFIFO)
+	}
+
+	return newFIFO(&perfFIFOReader{rd: rd}, decoder, doneCh, o), nil
+}
+
+type perfFIFOReader struct {
+	rd *perf.Reader
+	// rec is reused by each call to ReadInto.
+	rec perf.Record
+}
+
+func (r *perfFIFOReader) ReadInto(rec *fifoRecord) error {
+	if err := r.rd.ReadInto(&r.rec); err != nil {
+		rec.rawSample = rec.rawSample[:0]
+		rec.cpu = -1
+		rec.lostSamples = 0
+		rec.remaining = 0
+		return err
+	}
+
+	rec.rawSample = r.rec.RawSample
+	rec.cpu = r.rec.CPU
+	rec.lostSamples = r.rec.LostSamples
+	rec.remaining = r.rec.Remaining
+	return nil
+}
+
+func (r *perfFIFOReader) Close() error {
+	return r.rd.Close()
+}
@@ -0,0 +1,278 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/alexandremahdhaoui/tooling/pkg/flaterrors"
+)
+
+var ErrInvalidRecording = errors.New("invalid fifo recording")
+
+// recordingMagic starts every file written by the WithRecorder() option.
+//
+// The file format is as follows, in little endian:
+//
+//	magic [8]byte
+//	records: {
+//	    timestamp int64  // unix nanoseconds.
+//	    cpu       int32  // -1 if unknown.
+//	    len       uint32
+//	    sample    [len]byte
+//	}[]
+const recordingMagic = "EBPFREC1"
+
+// recordHeaderSize is the size of the header preceding each sample.
+const recordHeaderSize = 16
+
+// maxRecordedSampleSize bounds the length of a recorded sample, so that a
+// corrupted recording cannot trigger arbitrarily large allocations.
+const maxRecordedSampleSize = 1 << 24
+
+// -------------------------------------------------------------------
+// -- RECORD
+// -------------------------------------------------------------------
+
+// WithRecorder tees the raw records read by a FIFO into w, along with
+// their timestamp. The recording can be replayed using NewReplayFIFO().
+//
+// Records are written as they are read; please wrap w with a buffered
+// writer if needed. Errors writing to w are logged and do not interrupt
+// the FIFO.
+func WithRecorder(w io.Writer) Option {
+	return func(o *options) {
+		o.recorder = w
+	}
+}
+
+type recordingFIFOReader struct {
+	rd fifoReader
+	w  io.Writer
+	// buf is reused to encode the header of each record.
+	buf    []byte
+	failed bool
+}
+
+// newRecordingFIFOReader writes the recording magic to w right away, so
+// that a recording without any record can be replayed.
+func newRecordingFIFOReader(rd fifoReader, w io.Writer) *recordingFIFOReader {
+	r := &recordingFIFOReader{
+		rd:     rd,
+		w:      w,
+		buf:    make([]byte, 0, recordHeaderSize),
+		failed: false,
+	}
+
+	if _, err := io.WriteString(w, recordingMagic); err != nil {
+		r.fail(err)
+	}
+
+	return r
+}
+
+func (r *recordingFIFOReader) ReadInto(rec *fifoRecord) error {
+	if err := r.rd.ReadInto(rec); err != nil {
+		return err
+	}
+
+	if rec.lostSamples == 0 && !r.failed {
+		if err := r.write(rec); err != nil {
+			r.fail(err)
+		}
+	}
+
+	return nil
+}
+
+func (r *recordingFIFOReader) write(rec *fifoRecord) error {
+	r.buf = r.buf[:0]
+	r.buf = binary.LittleEndian.AppendUint64(r.buf, uint64(time.Now().UnixNano()))
+	r.buf = binary.LittleEndian.AppendUint32(r.buf, uint32(int32(rec.cpu)))
+	r.buf = binary.LittleEndian.AppendUint32(r.buf, uint32(len(rec.rawSample)))
+
+	if _, err := r.w.Write(r.buf); err != nil {
+		return err
+	}
+
+	_, err := r.w.Write(rec.rawSample)
+	return err
+}
+
+// fail stops the recording. It logs err only once: the writer is unlikely
+// to recover.
+func (r *recordingFIFOReader) fail(err error) {
+	r.failed = true
+	slog.ErrorContext(
+		context.TODO(),
+		"an error occured recording fifo records",
+		"err",
+		err.Error(),
+	)
+}
+
+func (r *recordingFIFOReader) Close() error {
+	return r.rd.Close()
+}
+
+// -------------------------------------------------------------------
+// -- REPLAY
+// -------------------------------------------------------------------
+
+// WithReplaySpeed configures the speed at which NewReplayFIFO() plays back
+// records, e.g. 1 for the original speed or 10 for 10x faster. Values lower
+// than or equal to 0 play back records as fast as possible. Defaults to 1.
+func WithReplaySpeed(speed float64) Option {
+	return func(o *options) {
+		o.replaySpeed = speed
+	}
+}
+
+// NewReplayFIFO creates a FIFO[T] playing back the records of a file
+// written with the WithRecorder() option. The FIFO is closed once all
+// records have been played back.
+//
+// Generics constraints:
+// - T must be a **struct**.
+// - T must not be a pointer.
+// - T must not be an interface.
+//
+// doneCh is a channel used to notify the FIFO can no longer be used.
+// Closing doneCh closes the FIFO.
+func NewReplayFIFO[T any](path string, doneCh <-chan struct{}, opts ...Option) (FIFO[T], error) {
+	o := newOptions(opts...)
+
+	decoder, err := newDecoder[T]("replay: record", o)
+	if err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewFIFO)
+	}
+
+	rd, err := newReplayFIFOReader(path, o.replaySpeed)
+	if err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewFIFO)
+	}
+
+	return newFIFO(rd, decoder, doneCh, o), nil
+}
+
+type replayFIFOReader struct {
+	file  *os.File
+	r     *bufio.Reader
+	speed float64
+	// start & firstTimestamp are set when the first record is read.
+	start          time.Time
+	firstTimestamp int64
+	header         [recordHeaderSize]byte
+
+	closeCh   chan struct{}
+	closeOnce *sync.Once
+}
+
+func newReplayFIFOReader(path string, speed float64) (*replayFIFOReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(file)
+
+	magic := make([]byte, len(recordingMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != recordingMagic {
+		_ = file.Close()
+		return nil, fmt.Errorf("%w: %s: missing %q header", ErrInvalidRecording, path, recordingMagic)
+	}
+
+	return &replayFIFOReader{
+		file:      file,
+		r:         r,
+		speed:     speed,
+		closeCh:   make(chan struct{}),
+		closeOnce: &sync.Once{},
+	}, nil
+}
+
+// ReadInto returns io.EOF once all records have been played back.
+func (r *replayFIFOReader) ReadInto(rec *fifoRecord) error {
+	select {
+	case <-r.closeCh:
+		return os.ErrClosed
+	default:
+	}
+
+	if _, err := io.ReadFull(r.r, r.header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.EOF
+		}
+		return flaterrors.Join(err, ErrInvalidRecording)
+	}
+
+	timestamp := int64(binary.LittleEndian.Uint64(r.header[0:8]))
+	cpu := int32(binary.LittleEndian.Uint32(r.header[8:12]))
+	length := binary.LittleEndian.Uint32(r.header[12:16])
+
+	if length > maxRecordedSampleSize {
+		return fmt.Errorf("%w: sample of %d bytes exceeds %d bytes", ErrInvalidRecording, length, maxRecordedSampleSize)
+	}
+
+	// Reuse the buffer of the previous record.
+	rec.rawSample = slices.Grow(rec.rawSample[:0], int(length))[:length]
+	if _, err := io.ReadFull(r.r, rec.rawSample); err != nil {
+		return flaterrors.Join(err, ErrInvalidRecording)
+	}
+	rec.cpu = int(cpu)
+	rec.lostSamples = 0
+	rec.remaining = 0
+
+	if r.speed <= 0 {
+		rec.remaining = r.r.Buffered()
+		return nil
+	}
+
+	if r.start.IsZero() {
+		r.start = time.Now()
+		r.firstTimestamp = timestamp
+	}
+
+	elapsed := time.Duration(float64(timestamp-r.firstTimestamp) / r.speed)
+	timer := time.NewTimer(time.Until(r.start.Add(elapsed)))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-r.closeCh:
+		return os.ErrClosed
+	}
+}
+
+func (r *replayFIFOReader) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.closeCh)
+		err = r.file.Close()
+	})
+	return err
+}
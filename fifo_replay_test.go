@@ -0,0 +1,160 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeRecording writes a recording of samples to a temporary file and
+// returns its path.
+func writeRecording(t *testing.T, samples ...[]byte) string {
+	t.Helper()
+
+	buf := []byte(recordingMagic)
+	for i, sample := range samples {
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(i))
+		buf = binary.LittleEndian.AppendUint32(buf, 0)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(sample)))
+		buf = append(buf, sample...)
+	}
+
+	path := filepath.Join(t.TempDir(), "recording")
+	if err := os.WriteFile(path, buf, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestReplayFIFOReaderReusesBuffer(t *testing.T) {
+	// 1 record, then 1 warm-up & 100 runs of AllocsPerRun.
+	samples := make([][]byte, 0, 102)
+	for i := range cap(samples) {
+		samples = append(samples, make([]byte, 64-i%8))
+	}
+
+	rd, err := newReplayFIFOReader(writeRecording(t, samples...), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	var rec fifoRecord
+	if err := rd.ReadInto(&rec); err != nil {
+		t.Fatal(err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := rd.ReadInto(&rec); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if allocs != 0 {
+		t.Fatalf("want 0 allocations per record; got %v", allocs)
+	}
+
+	if err := rd.ReadInto(&rec); !errors.Is(err, io.EOF) {
+		t.Fatalf("want io.EOF; got %v", err)
+	}
+}
+
+func TestReplayFIFOReaderRejectsLargeSample(t *testing.T) {
+	buf := []byte(recordingMagic)
+	buf = binary.LittleEndian.AppendUint64(buf, 0)
+	buf = binary.LittleEndian.AppendUint32(buf, 0)
+	buf = binary.LittleEndian.AppendUint32(buf, maxRecordedSampleSize+1)
+
+	path := filepath.Join(t.TempDir(), "recording")
+	if err := os.WriteFile(path, buf, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	rd, err := newReplayFIFOReader(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	var rec fifoRecord
+	if err := rd.ReadInto(&rec); !errors.Is(err, ErrInvalidRecording) {
+		t.Fatalf("want ErrInvalidRecording; got %v", err)
+	}
+}
+
+func TestRecordingFIFOReaderRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		n    int
+	}{
+		{name: "empty recording", n: 0},
+		{name: "recording", n: 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sample := []byte{1, 2, 3, 4}
+			path := filepath.Join(t.TempDir(), "recording")
+
+			f, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := newRecordingFIFOReader(newFakeFIFOReader(sample, tc.n), f)
+
+			var rec fifoRecord
+			for range tc.n {
+				if err := recorder.ReadInto(&rec); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := recorder.ReadInto(&rec); !errors.Is(err, io.EOF) {
+				t.Fatalf("want io.EOF; got %v", err)
+			}
+
+			if err := errors.Join(recorder.Close(), f.Close()); err != nil {
+				t.Fatal(err)
+			}
+
+			rd, err := newReplayFIFOReader(path, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rd.Close()
+
+			for range tc.n {
+				if err := rd.ReadInto(&rec); err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(rec.rawSample, sample) {
+					t.Fatalf("want sample %v; got %v", sample, rec.rawSample)
+				}
+			}
+
+			if err := rd.ReadInto(&rec); !errors.Is(err, io.EOF) {
+				t.Fatalf("want io.EOF; got %v", err)
+			}
+		})
+	}
+}
@@ -6,12 +6,12 @@ require (
 	github.com/alexandremahdhaoui/tooling v0.1.4
 	github.com/cilium/ebpf v0.18.0
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/sys v0.30.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.2-0.20180830191138-d8f796af33cc // indirect
 	github.com/pmezard/go-difflib v1.0.1-0.20181226105442-5d4384ee4fb2 // indirect
 	github.com/stretchr/objx v0.5.2 // indirect
-	golang.org/x/sys v0.30.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
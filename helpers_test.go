@@ -0,0 +1,252 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/rlimit"
+	"golang.org/x/sys/unix"
+)
+
+// -------------------------------------------------------------------
+// -- HELPERS
+// -------------------------------------------------------------------
+
+// newTestMap creates a bpf map closed at the end of the test. The test is
+// skipped if the process is not allowed to create bpf maps.
+func newTestMap(tb testing.TB, spec *ebpf.MapSpec) *ebpf.Map {
+	tb.Helper()
+
+	if err := rlimit.RemoveMemlock(); err != nil {
+		tb.Skipf("cannot remove memlock rlimit: %v", err)
+	}
+
+	m, err := ebpf.NewMap(spec)
+	if errors.Is(err, unix.EPERM) || errors.Is(err, ebpf.ErrNotSupported) {
+		tb.Skipf("cannot create bpf map: %v", err)
+	} else if err != nil {
+		tb.Fatal(err)
+	}
+
+	tb.Cleanup(func() { _ = m.Close() })
+
+	return m
+}
+
+// newTestVariable creates a bpf variable of size bytes backed by a
+// memory-mapped array map.
+func newTestVariable(tb testing.TB, size uint32) variable {
+	tb.Helper()
+
+	m := newTestMap(tb, &ebpf.MapSpec{
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  size,
+		MaxEntries: 1,
+		Flags:      unix.BPF_F_MMAPABLE,
+	})
+
+	mem, err := mmapDataSection(m)
+	if err != nil {
+		tb.Skipf("cannot memory-map bpf map: %v", err)
+	}
+	tb.Cleanup(func() { _ = mem.close() })
+
+	obj, err := mem.variable(0, 0, int(size))
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return obj
+}
+
+// newTestMapSides creates the `a` & `b` maps of a double-buffered data
+// structure.
+func newTestMapSides(tb testing.TB, typ ebpf.MapType, keySize, valueSize, maxEntries uint32) (a, b *ebpf.Map) {
+	tb.Helper()
+
+	spec := &ebpf.MapSpec{
+		Type:       typ,
+		KeySize:    keySize,
+		ValueSize:  valueSize,
+		MaxEntries: maxEntries,
+	}
+
+	return newTestMap(tb, spec), newTestMap(tb, spec)
+}
+
+// newTestBPFMap creates a Map[uint32, uint32]. activePointer is created if
+// nil.
+func newTestBPFMap(tb testing.TB, activePointer variable, doneCh <-chan struct{}, opts ...Option) *bpfMap[uint32, uint32] {
+	tb.Helper()
+
+	if activePointer == nil {
+		activePointer = newTestVariable(tb, 1)
+	}
+
+	a, b := newTestMapSides(tb, ebpf.Hash, 4, 4, 64)
+	m, err := newMap[uint32, uint32](
+		a, b,
+		newTestVariable(tb, 4), newTestVariable(tb, 4),
+		activePointer,
+		doneCh,
+		opts...,
+	)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	tb.Cleanup(func() { _ = m.Close() })
+
+	return m.(*bpfMap[uint32, uint32])
+}
+
+// newTestBPFArray creates an Array[uint32]. activePointer is created if
+// nil.
+func newTestBPFArray(tb testing.TB, activePointer variable, doneCh <-chan struct{}, opts ...Option) *bpfArray[uint32] {
+	tb.Helper()
+
+	if activePointer == nil {
+		activePointer = newTestVariable(tb, 1)
+	}
+
+	a, b := newTestMapSides(tb, ebpf.Array, 4, 4, 64)
+	arr, err := newArray[uint32](
+		a, b,
+		newTestVariable(tb, 4), newTestVariable(tb, 4),
+		activePointer,
+		doneCh,
+		opts...,
+	)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	tb.Cleanup(func() { _ = arr.Close() })
+
+	return arr.(*bpfArray[uint32])
+}
+
+// newTestBPFVariable creates a Variable[uint32] backed by obj. obj is
+// created if nil.
+func newTestBPFVariable(tb testing.TB, obj variable, doneCh <-chan struct{}, opts ...Option) *bpfVariable[uint32] {
+	tb.Helper()
+
+	if obj == nil {
+		obj = newTestVariable(tb, 4)
+	}
+
+	bv := newVariable[uint32](obj, nil, doneCh, opts...)
+	tb.Cleanup(func() { _ = bv.Close() })
+
+	return bv
+}
+
+// testDataSectionVar is a variable of a data section created by
+// newTestDataSection.
+type testDataSectionVar struct {
+	name string
+	typ  btf.Type
+}
+
+// newTestDataSection creates a memory-mapped `.bss` data section holding
+// vars. Variables are aligned on their size, up to 8 bytes.
+func newTestDataSection(tb testing.TB, vars ...testDataSectionVar) *ebpf.Map {
+	tb.Helper()
+
+	datasec := &btf.Datasec{Name: ".bss"}
+
+	var offset uint32
+	for _, v := range vars {
+		size, err := btf.Sizeof(v.typ)
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		if align := uint32(min(size, 8)); align&(align-1) == 0 {
+			offset = (offset + align - 1) / align * align
+		}
+
+		datasec.Vars = append(datasec.Vars, btf.VarSecinfo{
+			Type:   &btf.Var{Name: v.name, Type: v.typ, Linkage: btf.GlobalVar},
+			Offset: offset,
+			Size:   uint32(size),
+		})
+		offset += uint32(size)
+	}
+
+	// The value size is rounded up to 8 bytes, like libbpf does.
+	datasec.Size = (offset + 7) / 8 * 8
+
+	return newTestMap(tb, &ebpf.MapSpec{
+		Name:       ".bss",
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  datasec.Size,
+		MaxEntries: 1,
+		Flags:      unix.BPF_F_MMAPABLE,
+		Key:        &btf.Int{Size: 4},
+		Value:      datasec,
+	})
+}
+
+// newTestControlVariables returns the aLen, bLen & "activePointer"
+// variables of the data structures named names, following the
+// DefaultNaming().
+func newTestControlVariables(names ...string) []testDataSectionVar {
+	u8 := &btf.Int{Name: "__u8", Size: 1}
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+
+	vars := make([]testDataSectionVar, 0, 2*len(names)+1)
+	for _, name := range names {
+		n := DefaultNaming().resolve(name)
+		vars = append(vars,
+			testDataSectionVar{name: n.aLen, typ: u32},
+			testDataSectionVar{name: n.bLen, typ: u32},
+		)
+	}
+
+	return append(vars, testDataSectionVar{name: DefaultNaming().ActivePointer, typ: u8})
+}
+
+// newTestPinDir creates a directory in the bpf filesystem, removed at the
+// end of the test. The test is skipped if the bpf filesystem is not
+// mounted.
+func newTestPinDir(tb testing.TB) string {
+	tb.Helper()
+
+	var stat unix.Statfs_t
+	if err := unix.Statfs(bpffsPath, &stat); err != nil || stat.Type != unix.BPF_FS_MAGIC {
+		tb.Skipf("bpf filesystem is not mounted at %s", bpffsPath)
+	}
+
+	dir, err := os.MkdirTemp(bpffsPath, "ebpfstruct-test-")
+	if err != nil {
+		tb.Skipf("cannot create directory in bpf filesystem: %v", err)
+	}
+
+	tb.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	return dir
+}
+
+// bpffsPath is where the bpf filesystem is usually mounted.
+const bpffsPath = "/sys/fs/bpf"
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/alexandremahdhaoui/tooling/pkg/flaterrors"
+)
+
+var (
+	ErrDataStructureClosed  = errors.New("data structure is closed")
+	ErrClosingDataStructure = errors.New("closing data structure")
+)
+
+// -------------------------------------------------------------------
+// -- LIFECYCLE
+// -------------------------------------------------------------------
+
+// lifecycle implements Done() & Close() for the bpf data structures that
+// do not run a goroutine of their own, i.e. Array, Map, Variable &
+// DoubleBufferedVariable.
+//
+// The lifecycle ends when Close() is called or when the doneCh passed to
+// the constructor of the data structure is closed, whichever comes first.
+type lifecycle struct {
+	// closer releases the resources owned by the data structure, e.g. the
+	// bpf objects loaded from a pin path. It may be nil.
+	closer func() error
+
+	stopOnce *sync.Once
+	stopErr  error
+	// terminatedCh is closed once the lifecycle has ended.
+	terminatedCh chan struct{}
+}
+
+func newLifecycle(doneCh <-chan struct{}, closer func() error) *lifecycle {
+	lc := &lifecycle{
+		closer:       closer,
+		stopOnce:     &sync.Once{},
+		terminatedCh: make(chan struct{}),
+	}
+
+	// A nil doneCh is never closed: the lifecycle ends only when Close() is
+	// called.
+	if doneCh != nil {
+		go lc.closeOnSignal(doneCh)
+	}
+
+	return lc
+}
+
+// Done returns a channel that's closed once the lifecycle has ended.
+func (lc *lifecycle) Done() <-chan struct{} {
+	return lc.terminatedCh
+}
+
+// Close ends the lifecycle. It can be called multiple times.
+func (lc *lifecycle) Close() error {
+	return lc.stop()
+}
+
+// closed returns true once the lifecycle has ended.
+func (lc *lifecycle) closed() bool {
+	select {
+	case <-lc.terminatedCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// closeOnSignal ends the lifecycle when signal is closed. It returns early
+// if the lifecycle is ended by other means.
+func (lc *lifecycle) closeOnSignal(signal <-chan struct{}) {
+	select {
+	case <-signal:
+		_ = lc.stop()
+	case <-lc.terminatedCh:
+	}
+}
+
+// stop calls lc.closer and closes lc.terminatedCh.
+func (lc *lifecycle) stop() error {
+	lc.stopOnce.Do(func() {
+		if lc.closer != nil {
+			if err := lc.closer(); err != nil {
+				lc.stopErr = flaterrors.Join(err, ErrClosingDataStructure)
+			}
+		}
+
+		close(lc.terminatedCh)
+	})
+
+	return lc.stopErr
+}
+
+// withCloser registers a function releasing the resources owned by a data
+// structure when it is closed.
+func withCloser(closer func() error) Option {
+	return func(o *options) {
+		o.closer = closer
+	}
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// closable is implemented by all data structures.
+type closable interface {
+	Done() <-chan struct{}
+	Close() error
+}
+
+// lifecycleTestCases returns a constructor for each data structure, so
+// that every data structure is tested against the same lifecycle contract.
+func lifecycleTestCases() []struct {
+	name string
+	new  func(t *testing.T, doneCh <-chan struct{}) closable
+} {
+	return []struct {
+		name string
+		new  func(t *testing.T, doneCh <-chan struct{}) closable
+	}{
+		{
+			name: "Array",
+			new: func(t *testing.T, doneCh <-chan struct{}) closable {
+				return newTestBPFArray(t, nil, doneCh)
+			},
+		},
+		{
+			name: "Map",
+			new: func(t *testing.T, doneCh <-chan struct{}) closable {
+				return newTestBPFMap(t, nil, doneCh)
+			},
+		},
+		{
+			name: "Variable",
+			new: func(t *testing.T, doneCh <-chan struct{}) closable {
+				return newTestBPFVariable(t, nil, doneCh)
+			},
+		},
+		{
+			name: "FIFO",
+			new: func(t *testing.T, doneCh <-chan struct{}) closable {
+				f := newFIFO(newFakeFIFOReader(nil, -1), BinaryDecoder[uint32](), doneCh, newOptions())
+				t.Cleanup(func() { _ = f.Close() })
+				return f
+			},
+		},
+	}
+}
+
+func TestLifecycleDoneFiresWhenDoneChIsClosed(t *testing.T) {
+	for _, tc := range lifecycleTestCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			doneCh := make(chan struct{})
+			ds := tc.new(t, doneCh)
+
+			assertNotDone(t, ds)
+			close(doneCh)
+			assertDone(t, ds)
+		})
+	}
+}
+
+func TestLifecycleDoneFiresOnClose(t *testing.T) {
+	for _, tc := range lifecycleTestCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			ds := tc.new(t, make(chan struct{}))
+
+			assertNotDone(t, ds)
+
+			// -- Close can be called multiple times.
+			for range 2 {
+				if err := ds.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			assertDone(t, ds)
+		})
+	}
+}
+
+func TestLifecycleNilDoneCh(t *testing.T) {
+	for _, tc := range lifecycleTestCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+			ds := tc.new(t, nil)
+
+			// -- no goroutine waits for a nil doneCh.
+			if after := runtime.NumGoroutine(); after > before {
+				t.Fatalf("want at most %d goroutines; got %d", before, after)
+			}
+
+			assertNotDone(t, ds)
+
+			if err := ds.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			assertDone(t, ds)
+		})
+	}
+}
+
+func assertDone(t *testing.T, ds closable) {
+	t.Helper()
+
+	select {
+	case <-ds.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done() was not closed")
+	}
+}
+
+func assertNotDone(t *testing.T, ds closable) {
+	t.Helper()
+
+	select {
+	case <-ds.Done():
+		t.Fatal("Done() was closed")
+	default:
+	}
+}
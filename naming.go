@@ -0,0 +1,87 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"fmt"
+	"strings"
+)
+
+// -------------------------------------------------------------------
+// -- NAMING
+// -------------------------------------------------------------------
+
+// Naming describes how the 5 bpf objects a double-buffered data structure
+// depends on are named.
+//
+// Each field is a format string receiving the base name of the data
+// structure, e.g. "%s_a". A field without formatting verb is used as is,
+// e.g. an "activePointer" shared by many data structures.
+type Naming struct {
+	// A & B are the names of the internal maps.
+	A, B string
+	// ALen & BLen are the names of the bpf variables storing the length of
+	// the respective A or B map.
+	ALen, BLen string
+	// ActivePointer is the name of the "activePointer" bpf variable.
+	ActivePointer string
+}
+
+// DefaultNaming returns the following naming scheme:
+//   - <base>_a & <base>_b for the internal maps.
+//   - <base>_a_len & <base>_b_len for the length variables.
+//   - active_pointer for the "activePointer" variable.
+func DefaultNaming() Naming {
+	return Naming{
+		A:             "%s_a",
+		B:             "%s_b",
+		ALen:          "%s_a_len",
+		BLen:          "%s_b_len",
+		ActivePointer: "active_pointer",
+	}
+}
+
+// WithNaming overrides the DefaultNaming used to resolve the bpf objects of
+// a data structure by name.
+func WithNaming(naming Naming) Option {
+	return func(o *options) {
+		o.naming = naming
+	}
+}
+
+// names holds the resolved names of the bpf objects of a data structure.
+type names struct {
+	a, b          string
+	aLen, bLen    string
+	activePointer string
+}
+
+func (n Naming) resolve(base string) names {
+	return names{
+		a:             formatName(n.A, base),
+		b:             formatName(n.B, base),
+		aLen:          formatName(n.ALen, base),
+		bLen:          formatName(n.BLen, base),
+		activePointer: formatName(n.ActivePointer, base),
+	}
+}
+
+func formatName(format, base string) string {
+	if !strings.Contains(format, "%") {
+		return format
+	}
+	return fmt.Sprintf(format, base)
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import "io"
+
+// -------------------------------------------------------------------
+// -- OPTIONS
+// -------------------------------------------------------------------
+
+// Option configures the behavior of a bpf data structure at construction
+// time. Options that are irrelevant to a data structure are ignored.
+type Option func(*options)
+
+type options struct {
+	// cache enables serving reads from a userspace copy of the values
+	// last written by this process instead of issuing syscalls.
+	cache bool
+
+	// retryPolicy configures how deferred switchovers are retried.
+	retryPolicy RetryPolicy
+
+	// stateRecovery rebuilds the internal state from the kernel at
+	// construction time.
+	stateRecovery bool
+
+	// naming resolves the bpf objects of a data structure by name.
+	naming Naming
+	// dataSectionPinName is the name of the pinned data section holding
+	// the bpf variables of pinned data structures.
+	dataSectionPinName string
+
+	// bufferSize is the capacity of the channels returned by a FIFO.
+	bufferSize int
+	// overflowPolicy configures how a FIFO handles slow subscribers.
+	overflowPolicy OverflowPolicy
+	// broadcast allows a FIFO to be subscribed to multiple times.
+	broadcast bool
+	// decoder is the Decoder[T] used by a FIFO[T] to decode records.
+	decoder any
+	// batchSize is the maximum number of records in a FIFO batch.
+	batchSize int
+	// recorder receives the raw records read by a FIFO.
+	recorder io.Writer
+	// replaySpeed is the speed at which a replay FIFO plays back records.
+	replaySpeed float64
+
+	// closer releases the resources owned by a data structure when it is
+	// closed. It is not exposed to users.
+	closer func() error
+}
+
+func newOptions(opts ...Option) options {
+	o := options{
+		retryPolicy:        DefaultRetryPolicy(),
+		naming:             DefaultNaming(),
+		dataSectionPinName: DefaultDataSectionPinName,
+		overflowPolicy:     OverflowBlock,
+		batchSize:          DefaultBatchSize,
+		replaySpeed:        1,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithCache serves reads from a userspace copy of the last values written
+// by this process instead of reading them back from the kernel.
+//
+// It saves syscalls at the cost of keeping a copy of the data structure
+// in memory. Please note that changes performed by the bpf program or by
+// another process will not be observed.
+func WithCache() Option {
+	return func(o *options) {
+		o.cache = true
+	}
+}
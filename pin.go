@@ -0,0 +1,334 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexandremahdhaoui/tooling/pkg/flaterrors"
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+)
+
+// DefaultDataSectionPinName is the default name of the pinned data section
+// holding the bpf variables of pinned data structures.
+const DefaultDataSectionPinName = "bss"
+
+var (
+	ErrLoadingPinnedObjects = errors.New("loading pinned bpf objects")
+	ErrPinningObjects       = errors.New("pinning bpf objects")
+	ErrVariableNotFound     = errors.New("variable not found in data section")
+	ErrPinPathInUse         = errors.New("another bpf object is pinned at path")
+)
+
+// -------------------------------------------------------------------
+// -- PIN
+// -------------------------------------------------------------------
+
+// Pin pins the bpf objects a data structure depends on under dir, following
+// the naming scheme expected by NewArrayFromPinPath and NewMapFromPinPath:
+//   - the `a` & `b` maps are pinned using their names, e.g. <dir>/<name>_a.
+//   - the `aLen`, `bLen` & "activePointer" bpf variables are pinned through
+//     their data section, e.g. <dir>/bss.
+//
+// The data section can be shared by many data structures: Pin is a no-op
+// for objects already pinned at the same path, even through another handle.
+// It returns ErrPinPathInUse if another object is pinned at one of the
+// paths.
+//
+// The naming scheme can be configured using the WithNaming() and
+// WithDataSectionPinName() options.
+func Pin(dir, name string, a, b, dataSection *ebpf.Map, opts ...Option) error {
+	o := newOptions(opts...)
+	n := o.naming.resolve(name)
+
+	// -- ensure the data section holds the expected variables.
+	if _, err := lookupDataSectionVariables(dataSection, n); err != nil {
+		return flaterrors.Join(err, ErrPinningObjects)
+	}
+
+	for _, obj := range []struct {
+		m       *ebpf.Map
+		pinName string
+	}{
+		{m: a, pinName: n.a},
+		{m: b, pinName: n.b},
+		{m: dataSection, pinName: o.dataSectionPinName},
+	} {
+		if err := pinMap(obj.m, filepath.Join(dir, obj.pinName)); err != nil {
+			return flaterrors.Join(err, ErrPinningObjects)
+		}
+	}
+
+	return nil
+}
+
+// pinMap pins m at path. It is a no-op if m is already pinned at path by
+// another handle, e.g. a data section shared by many data structures.
+func pinMap(m *ebpf.Map, path string) error {
+	err := m.Pin(path)
+	if !errors.Is(err, os.ErrExist) {
+		return err
+	}
+
+	pinned, loadErr := ebpf.LoadPinnedMap(path, nil)
+	if loadErr != nil {
+		return flaterrors.Join(loadErr, err)
+	}
+	defer pinned.Close()
+
+	id, idErr := mapIDOf(m)
+	pinnedID, pinnedIDErr := mapIDOf(pinned)
+	if err := flaterrors.Join(idErr, pinnedIDErr); err != nil {
+		return err
+	}
+
+	if id != pinnedID {
+		return fmt.Errorf("%w: %s", ErrPinPathInUse, path)
+	}
+
+	return nil
+}
+
+// mapIDOf returns the ID of m.
+func mapIDOf(m *ebpf.Map) (ebpf.MapID, error) {
+	info, err := m.Info()
+	if err != nil {
+		return 0, err
+	}
+
+	id, ok := info.ID()
+	if !ok {
+		return 0, fmt.Errorf("map ID is not available: %w", ebpf.ErrNotSupported)
+	}
+
+	return id, nil
+}
+
+// WithDataSectionPinName overrides the DefaultDataSectionPinName.
+func WithDataSectionPinName(name string) Option {
+	return func(o *options) {
+		o.dataSectionPinName = name
+	}
+}
+
+// NewArrayFromPinPath creates an Array[T] from bpf objects pinned under dir
+// by Pin.
+//
+// The internal state is recovered from the kernel, as if the
+// WithStateRecovery() option was passed. The pinned bpf objects are
+// loaded by this function, hence they are closed by Close().
+//
+// doneCh is a channel used to notify the bpf data structures or bpf
+// program has been closed and they can no longer be used.
+func NewArrayFromPinPath[T any](dir, name string, doneCh <-chan struct{}, opts ...Option) (Array[T], error) {
+	objs, err := loadPinnedObjects(dir, name, newOptions(opts...))
+	if err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewArray)
+	}
+
+	arr, err := newArray[T](
+		objs.a, objs.b,
+		objs.aLen, objs.bLen,
+		objs.activePointer,
+		doneCh,
+		append([]Option{WithStateRecovery(), withCloser(objs.close)}, opts...)...,
+	)
+	if err != nil {
+		_ = objs.close()
+		return nil, err
+	}
+
+	return arr, nil
+}
+
+// NewMapFromPinPath creates a Map[K,V] from bpf objects pinned under dir by
+// Pin.
+//
+// The internal state is recovered from the kernel, as if the
+// WithStateRecovery() option was passed. The pinned bpf objects are
+// loaded by this function, hence they are closed by Close().
+//
+// doneCh is a channel used to notify the bpf data structures or bpf
+// program has been closed and they can no longer be used.
+func NewMapFromPinPath[K comparable, V any](dir, name string, doneCh <-chan struct{}, opts ...Option) (Map[K, V], error) {
+	objs, err := loadPinnedObjects(dir, name, newOptions(opts...))
+	if err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewMap)
+	}
+
+	m, err := newMap[K, V](
+		objs.a, objs.b,
+		objs.aLen, objs.bLen,
+		objs.activePointer,
+		doneCh,
+		append([]Option{WithStateRecovery(), withCloser(objs.close)}, opts...)...,
+	)
+	if err != nil {
+		_ = objs.close()
+		return nil, err
+	}
+
+	return m, nil
+}
+
+type pinnedObjects struct {
+	a, b          *ebpf.Map
+	dataSection   *ebpf.Map
+	aLen, bLen    variable
+	activePointer variable
+}
+
+func loadPinnedObjects(dir, name string, o options) (*pinnedObjects, error) {
+	n := o.naming.resolve(name)
+	objs := &pinnedObjects{}
+
+	var err error
+	for _, obj := range []struct {
+		out     **ebpf.Map
+		pinName string
+	}{
+		{out: &objs.a, pinName: n.a},
+		{out: &objs.b, pinName: n.b},
+		{out: &objs.dataSection, pinName: o.dataSectionPinName},
+	} {
+		if *obj.out, err = ebpf.LoadPinnedMap(filepath.Join(dir, obj.pinName), nil); err != nil {
+			_ = objs.close()
+			return nil, flaterrors.Join(err, ErrLoadingPinnedObjects)
+		}
+	}
+
+	vars, err := lookupDataSectionVariables(objs.dataSection, n)
+	if err != nil {
+		_ = objs.close()
+		return nil, flaterrors.Join(err, ErrLoadingPinnedObjects)
+	}
+
+	objs.aLen, objs.bLen, objs.activePointer = vars[0], vars[1], vars[2]
+
+	return objs, nil
+}
+
+// close closes the maps that have been loaded.
+func (objs *pinnedObjects) close() error {
+	errs := make([]error, 0)
+	for _, m := range []*ebpf.Map{objs.a, objs.b, objs.dataSection} {
+		if m != nil {
+			errs = append(errs, m.Close())
+		}
+	}
+	return flaterrors.Join(errs...)
+}
+
+// lookupDataSectionVariables returns the aLen, bLen & "activePointer"
+// variables named after n from the data section m, e.g. a `.bss` or `.data`
+// map, using its BTF. Like validateControlVariables, it validates that aLen &
+// bLen are __u32 and that "activePointer" is a __u8.
+//
+// Variables are read & written through the memory-mapped data section, hence
+// m must have been created with BPF_F_MMAPABLE.
+func lookupDataSectionVariables(m *ebpf.Map, n names) ([]variable, error) {
+	secinfos, err := dataSectionSecinfos(m)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := m.Info()
+	if err != nil {
+		return nil, err
+	}
+
+	mapID, _ := info.ID()
+
+	mem, err := mmapDataSection(m)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make([]variable, 0, 3)
+	for _, v := range []struct {
+		object string
+		name   string
+		size   uint32
+	}{
+		{object: "variable aLen", name: n.aLen, size: 4},
+		{object: "variable bLen", name: n.bLen, size: 4},
+		{object: "variable activePointer", name: n.activePointer, size: 1},
+	} {
+		secinfo, ok := secinfos[v.name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrVariableNotFound, v.name)
+		}
+
+		var typ btf.Type
+		if bv, ok := secinfo.Type.(*btf.Var); ok {
+			typ = bv.Type
+		}
+
+		if err := validateUint(v.object, uint64(secinfo.Size), typ, v.size); err != nil {
+			return nil, err
+		}
+
+		obj, err := mem.variable(mapID, int(secinfo.Offset), int(secinfo.Size))
+		if err != nil {
+			return nil, err
+		}
+
+		vars = append(vars, obj)
+	}
+
+	return vars, nil
+}
+
+// dataSectionSecinfos returns the BTF secinfos of the variables of the data
+// section m, indexed by variable name.
+func dataSectionSecinfos(m *ebpf.Map) (map[string]btf.VarSecinfo, error) {
+	info, err := m.Info()
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := m.Handle()
+	if err != nil {
+		return nil, err
+	}
+	defer handle.Close()
+
+	spec, err := handle.Spec(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	secinfos := make(map[string]btf.VarSecinfo)
+	for it := spec.Iterate(); it.Next(); {
+		datasec, ok := it.Type.(*btf.Datasec)
+		if !ok || !strings.HasSuffix(info.Name, datasec.Name) {
+			continue
+		}
+
+		for _, secinfo := range datasec.Vars {
+			if v, ok := secinfo.Type.(*btf.Var); ok {
+				secinfos[v.Name] = secinfo
+			}
+		}
+	}
+
+	return secinfos, nil
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/cilium/ebpf"
+)
+
+func TestPinArrayRoundTrip(t *testing.T) {
+	dir := newTestPinDir(t)
+	dataSection := newTestDataSection(t, newTestControlVariables("arr")...)
+	a, b := newTestMapSides(t, ebpf.Array, 4, 4, 64)
+
+	vars, err := lookupDataSectionVariables(dataSection, DefaultNaming().resolve("arr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arr, err := newArray[uint32](a, b, vars[0], vars[1], vars[2], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arr.Close()
+
+	want := []uint32{1, 2, 3}
+	if err := arr.Set(want); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Pin(dir, "arr", a, b, dataSection); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := NewArrayFromPinPath[uint32](dir, "arr", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+
+	got, err := loaded.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("want %v; got %v", want, got)
+	}
+}
+
+func TestPinMapRoundTrip(t *testing.T) {
+	dir := newTestPinDir(t)
+	dataSection := newTestDataSection(t, newTestControlVariables("m")...)
+	a, b := newTestMapSides(t, ebpf.Hash, 4, 4, 64)
+
+	vars, err := lookupDataSectionVariables(dataSection, DefaultNaming().resolve("m"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := newMap[uint32, uint32](a, b, vars[0], vars[1], vars[2], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	want := map[uint32]uint32{1: 2, 3: 4}
+	if err := m.Set(want); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Pin(dir, "m", a, b, dataSection); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := NewMapFromPinPath[uint32, uint32](dir, "m", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+
+	got, err := loaded.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !maps.Equal(got, want) {
+		t.Fatalf("want %v; got %v", want, got)
+	}
+}
+
+func TestPinSharedDataSection(t *testing.T) {
+	dir := newTestPinDir(t)
+	dataSection := newTestDataSection(t, newTestControlVariables("arr", "m")...)
+
+	arrA, arrB := newTestMapSides(t, ebpf.Array, 4, 4, 64)
+	if err := Pin(dir, "arr", arrA, arrB, dataSection); err != nil {
+		t.Fatal(err)
+	}
+
+	// -- the data section is already pinned through another handle.
+	info, err := dataSection.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, _ := info.ID()
+	handle, err := ebpf.NewMapFromID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handle.Close()
+
+	mA, mB := newTestMapSides(t, ebpf.Hash, 4, 4, 64)
+	if err := Pin(dir, "m", mA, mB, handle); err != nil {
+		t.Fatal(err)
+	}
+
+	// -- another data section is pinned at the same path.
+	other := newTestDataSection(t, newTestControlVariables("other")...)
+	otherA, otherB := newTestMapSides(t, ebpf.Hash, 4, 4, 64)
+	if err := Pin(dir, "other", otherA, otherB, other); !errors.Is(err, ErrPinPathInUse) {
+		t.Fatalf("want ErrPinPathInUse; got %v", err)
+	}
+}
@@ -15,7 +15,11 @@
  */
 package fakebpfstruct
 
-import "github.com/alexandremahdhaoui/ebpfstruct"
+import (
+	"sync"
+
+	"github.com/alexandremahdhaoui/ebpfstruct"
+)
 
 var _ ebpfstruct.Array[any] = &Array[any]{}
 
@@ -26,6 +30,7 @@ func NewArray[T any]() *Array[T] {
 		activePtr: false,
 		expector:  expector{},
 		doneCh:    make(chan struct{}),
+		doneOnce:  &sync.Once{},
 	}
 }
 
@@ -33,6 +38,7 @@ type Array[T any] struct {
 	a, b      []T
 	activePtr bool
 	doneCh    chan struct{}
+	doneOnce  *sync.Once
 	expector
 }
 
@@ -47,9 +53,35 @@ func (a *Array[T]) Set(values []T) error {
 }
 
 // SetAndDeferSwitchover implements Array.
-func (a *Array[T]) SetAndDeferSwitchover(values []T) (func(), error) {
+func (a *Array[T]) SetAndDeferSwitchover(values []T) (func() error, func(), error) {
 	a.setPassive(values)
-	return a.switchover, a.checkExpectation("SetAndDeferSwitchover")
+	sw := NewSwitchover(a.switchover)
+	return sw.Commit, sw.Abort, a.checkExpectation("SetAndDeferSwitchover")
+}
+
+// PrepareSwitchover implements Array.
+func (a *Array[T]) PrepareSwitchover(values []T) (ebpfstruct.Switchover, error) {
+	a.setPassive(values)
+	return NewSwitchover(a.switchover), a.checkExpectation("PrepareSwitchover")
+}
+
+// Get implements Array.
+func (a *Array[T]) Get() ([]T, error) {
+	if err := a.checkExpectation("Get"); err != nil {
+		return nil, err
+	}
+	return a.GetActiveArray(), nil
+}
+
+// GetPassive implements Array.
+func (a *Array[T]) GetPassive() ([]T, error) {
+	if err := a.checkExpectation("GetPassive"); err != nil {
+		return nil, err
+	}
+	if a.activePtr {
+		return a.a, nil
+	}
+	return a.b, nil
 }
 
 // -- GET ACTIVE
@@ -62,6 +94,16 @@ func (a *Array[T]) GetActiveArray() []T {
 	return a.a
 }
 
+// Close implements ebpfstruct.Array. If the expected error is nil, it closes
+// the channel returned by Done().
+func (a *Array[T]) Close() error {
+	if err := a.checkExpectation("Close"); err != nil {
+		return err
+	}
+	a.CloseDoneChannel()
+	return nil
+}
+
 func (a *Array[T]) Done() <-chan struct{} {
 	return a.doneCh
 }
@@ -70,7 +112,7 @@ func (a *Array[T]) Done() <-chan struct{} {
 // that the work done on behalf of this Array[T] has been gracefully
 // terminated.
 func (a *Array[T]) CloseDoneChannel() {
-	close(a.doneCh)
+	a.doneOnce.Do(func() { close(a.doneCh) })
 }
 
 // -- HELPERS
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package fakebpfstruct
+
+import (
+	"sync"
+
+	"github.com/alexandremahdhaoui/ebpfstruct"
+)
+
+var _ ebpfstruct.DoubleBufferedVariable[any] = &DoubleBufferedVariable[any]{}
+
+func NewDoubleBufferedVariable[T any]() *DoubleBufferedVariable[T] {
+	return &DoubleBufferedVariable[T]{
+		a:         *new(T),
+		b:         *new(T),
+		activePtr: false,
+		expector:  expector{},
+		doneCh:    make(chan struct{}),
+		doneOnce:  &sync.Once{},
+	}
+}
+
+type DoubleBufferedVariable[T any] struct {
+	a, b      T
+	activePtr bool
+	doneCh    chan struct{}
+	doneOnce  *sync.Once
+	expector
+}
+
+// Set implements DoubleBufferedVariable.
+func (v *DoubleBufferedVariable[T]) Set(value T) error {
+	v.setPassive(value)
+	if err := v.checkExpectation("Set"); err != nil {
+		return err
+	}
+	v.switchover()
+	return nil
+}
+
+// SetAndDeferSwitchover implements DoubleBufferedVariable.
+func (v *DoubleBufferedVariable[T]) SetAndDeferSwitchover(value T) (func() error, func(), error) {
+	v.setPassive(value)
+	sw := NewSwitchover(v.switchover)
+	return sw.Commit, sw.Abort, v.checkExpectation("SetAndDeferSwitchover")
+}
+
+// PrepareSwitchover implements DoubleBufferedVariable.
+func (v *DoubleBufferedVariable[T]) PrepareSwitchover(value T) (ebpfstruct.Switchover, error) {
+	v.setPassive(value)
+	return NewSwitchover(v.switchover), v.checkExpectation("PrepareSwitchover")
+}
+
+// Get implements DoubleBufferedVariable.
+func (v *DoubleBufferedVariable[T]) Get() (T, error) {
+	if err := v.checkExpectation("Get"); err != nil {
+		return *new(T), err
+	}
+	return v.GetActiveValue(), nil
+}
+
+// GetPassive implements DoubleBufferedVariable.
+func (v *DoubleBufferedVariable[T]) GetPassive() (T, error) {
+	if err := v.checkExpectation("GetPassive"); err != nil {
+		return *new(T), err
+	}
+	if v.activePtr {
+		return v.a, nil
+	}
+	return v.b, nil
+}
+
+// -- GET ACTIVE
+
+// It returns the actual value of the variable in active state.
+func (v *DoubleBufferedVariable[T]) GetActiveValue() T {
+	if v.activePtr {
+		return v.b
+	}
+	return v.a
+}
+
+// Close implements DoubleBufferedVariable. If the expected error is nil, it closes
+// the channel returned by Done().
+func (v *DoubleBufferedVariable[T]) Close() error {
+	if err := v.checkExpectation("Close"); err != nil {
+		return err
+	}
+	v.CloseDoneChannel()
+	return nil
+}
+
+func (v *DoubleBufferedVariable[T]) Done() <-chan struct{} {
+	return v.doneCh
+}
+
+// It will close the channel returned by Done(), notifying when closed
+// that the work done on behalf of this DoubleBufferedVariable[T] has been
+// gracefully terminated.
+func (v *DoubleBufferedVariable[T]) CloseDoneChannel() {
+	v.doneOnce.Do(func() { close(v.doneCh) })
+}
+
+// -- HELPERS
+
+func (v *DoubleBufferedVariable[T]) setPassive(value T) {
+	if v.activePtr {
+		v.a = value
+	} else {
+		v.b = value
+	}
+}
+
+func (v *DoubleBufferedVariable[T]) switchover() {
+	v.activePtr = !v.activePtr
+}
@@ -15,29 +15,70 @@
  */
 package fakebpfstruct
 
-import "github.com/alexandremahdhaoui/ebpfstruct"
+import (
+	"context"
+	"sync"
+
+	"github.com/alexandremahdhaoui/ebpfstruct"
+)
 
 var _ ebpfstruct.FIFO[any] = &FIFO[any]{}
 
 func NewFIFO[T any]() *FIFO[T] {
 	return &FIFO[T]{
-		Chan:     make(chan T),
-		expector: expector{},
-		doneCh:   make(chan struct{}),
+		Chan:       make(chan T),
+		ResultChan: make(chan ebpfstruct.FIFOResult[T]),
+		BatchChan:  make(chan []T),
+		expector:   expector{},
+		doneCh:     make(chan struct{}),
+		doneOnce:   &sync.Once{},
 	}
 }
 
 type FIFO[T any] struct {
-	Chan   chan T
-	doneCh chan struct{}
+	Chan       chan T
+	ResultChan chan ebpfstruct.FIFOResult[T]
+	BatchChan  chan []T
+	// FIFOStats is returned by Stats().
+	FIFOStats ebpfstruct.FIFOStats
+	doneCh    chan struct{}
+	doneOnce  *sync.Once
 	expector
 }
 
 // Subscribe implements FIFO.
-func (f *FIFO[T]) Subscribe() (<-chan T, error) {
+func (f *FIFO[T]) Subscribe(ctx context.Context) (<-chan T, error) {
 	return f.Chan, f.checkExpectation("Subscribe")
 }
 
+// SubscribeWithErrors implements FIFO.
+func (f *FIFO[T]) SubscribeWithErrors(ctx context.Context) (<-chan ebpfstruct.FIFOResult[T], error) {
+	return f.ResultChan, f.checkExpectation("SubscribeWithErrors")
+}
+
+// SubscribeBatch implements FIFO.
+func (f *FIFO[T]) SubscribeBatch(ctx context.Context) (<-chan []T, error) {
+	return f.BatchChan, f.checkExpectation("SubscribeBatch")
+}
+
+// RecycleBatch implements FIFO.
+func (f *FIFO[T]) RecycleBatch(batch []T) {}
+
+// Stats implements FIFO.
+func (f *FIFO[T]) Stats() ebpfstruct.FIFOStats {
+	return f.FIFOStats
+}
+
+// Close implements FIFO. If the expected error is nil, it closes the
+// channel returned by Done().
+func (f *FIFO[T]) Close() error {
+	if err := f.checkExpectation("Close"); err != nil {
+		return err
+	}
+	f.CloseDoneChannel()
+	return nil
+}
+
 func (f *FIFO[T]) Done() <-chan struct{} {
 	return f.doneCh
 }
@@ -46,5 +87,5 @@ func (f *FIFO[T]) Done() <-chan struct{} {
 // that the work done on behalf of this FIFO[T] has been gracefully
 // terminated.
 func (f *FIFO[T]) CloseDoneChannel() {
-	close(f.doneCh)
+	f.doneOnce.Do(func() { close(f.doneCh) })
 }
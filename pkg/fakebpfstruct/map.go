@@ -16,6 +16,10 @@
 package fakebpfstruct
 
 import (
+	"iter"
+	"maps"
+	"sync"
+
 	"github.com/alexandremahdhaoui/ebpfstruct"
 )
 
@@ -28,6 +32,7 @@ func NewMap[K comparable, V any]() *Map[K, V] {
 		activePtr: false,
 		expector:  expector{},
 		doneCh:    make(chan struct{}),
+		doneOnce:  &sync.Once{},
 	}
 }
 
@@ -35,9 +40,36 @@ type Map[K comparable, V any] struct {
 	a, b      map[K]V
 	activePtr bool
 	doneCh    chan struct{}
+	doneOnce  *sync.Once
 	expector
 }
 
+// Lookup implements Map.
+func (m *Map[K, V]) Lookup(k K) (V, bool, error) {
+	if err := m.checkExpectation("Lookup"); err != nil {
+		return *new(V), false, err
+	}
+	v, ok := m.GetActiveMap()[k]
+	return v, ok, nil
+}
+
+// GetAll implements Map.
+func (m *Map[K, V]) GetAll() (map[K]V, error) {
+	if err := m.checkExpectation("GetAll"); err != nil {
+		return nil, err
+	}
+	return maps.Clone(m.GetActiveMap()), nil
+}
+
+// Iterate implements Map.
+func (m *Map[K, V]) Iterate() (iter.Seq2[K, V], func() error) {
+	err := m.checkExpectation("Iterate")
+	if err != nil {
+		return func(func(K, V) bool) {}, func() error { return err }
+	}
+	return maps.All(m.GetActiveMap()), func() error { return nil }
+}
+
 // BatchDelete removes keys in batch from the active map.
 func (m *Map[K, V]) BatchDelete(keys []K) error {
 	if err := m.checkExpectation("BatchDelete"); err != nil {
@@ -73,9 +105,16 @@ func (m *Map[K, V]) Set(newMap map[K]V) error {
 }
 
 // SetAndDeferSwitchover implements Map.
-func (m *Map[K, V]) SetAndDeferSwitchover(newMap map[K]V) (func(), error) {
+func (m *Map[K, V]) SetAndDeferSwitchover(newMap map[K]V) (func() error, func(), error) {
 	m.setPassiveMap(newMap)
-	return m.switchover, m.checkExpectation("SetAndDeferSwitchover")
+	sw := NewSwitchover(m.switchover)
+	return sw.Commit, sw.Abort, m.checkExpectation("SetAndDeferSwitchover")
+}
+
+// PrepareSwitchover implements Map.
+func (m *Map[K, V]) PrepareSwitchover(newMap map[K]V) (ebpfstruct.Switchover, error) {
+	m.setPassiveMap(newMap)
+	return NewSwitchover(m.switchover), m.checkExpectation("PrepareSwitchover")
 }
 
 // -- GET ACTIVE
@@ -90,6 +129,16 @@ func (m *Map[K, V]) GetActiveMap() map[K]V {
 
 // -- DONE
 
+// Close implements ebpfstruct.Map. If the expected error is nil, it closes
+// the channel returned by Done().
+func (m *Map[K, V]) Close() error {
+	if err := m.checkExpectation("Close"); err != nil {
+		return err
+	}
+	m.CloseDoneChannel()
+	return nil
+}
+
 func (m *Map[K, V]) Done() <-chan struct{} {
 	return m.doneCh
 }
@@ -98,7 +147,7 @@ func (m *Map[K, V]) Done() <-chan struct{} {
 // that the work done on behalf of this Map[K,V] has been gracefully
 // terminated.
 func (m *Map[K, V]) CloseDoneChannel() {
-	close(m.doneCh)
+	m.doneOnce.Do(func() { close(m.doneCh) })
 }
 
 // -- HELPERS
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package fakebpfstruct
+
+import (
+	"sync"
+
+	"github.com/alexandremahdhaoui/ebpfstruct"
+)
+
+var _ ebpfstruct.Producer[any] = &Producer[any]{}
+
+func NewProducer[T any]() *Producer[T] {
+	return &Producer[T]{
+		Published: make([]T, 0),
+		expector:  expector{},
+		doneCh:    make(chan struct{}),
+		doneOnce:  &sync.Once{},
+	}
+}
+
+type Producer[T any] struct {
+	// Published holds the values successfully published, in order.
+	Published []T
+	doneCh    chan struct{}
+	doneOnce  *sync.Once
+	expector
+}
+
+// Publish implements ebpfstruct.Producer.
+func (p *Producer[T]) Publish(v T) error {
+	if err := p.checkExpectation("Publish"); err != nil {
+		return err
+	}
+	p.Published = append(p.Published, v)
+	return nil
+}
+
+// PublishBatch implements ebpfstruct.Producer.
+func (p *Producer[T]) PublishBatch(values []T) error {
+	if err := p.checkExpectation("PublishBatch"); err != nil {
+		return err
+	}
+	p.Published = append(p.Published, values...)
+	return nil
+}
+
+// Close implements ebpfstruct.Producer. If the expected error is nil, it
+// closes the channel returned by Done().
+func (p *Producer[T]) Close() error {
+	if err := p.checkExpectation("Close"); err != nil {
+		return err
+	}
+	p.CloseDoneChannel()
+	return nil
+}
+
+func (p *Producer[T]) Done() <-chan struct{} {
+	return p.doneCh
+}
+
+// It will close the channel returned by Done(), notifying when closed
+// that the work done on behalf of this Producer[T] has been gracefully
+// terminated.
+func (p *Producer[T]) CloseDoneChannel() {
+	p.doneOnce.Do(func() { close(p.doneCh) })
+}
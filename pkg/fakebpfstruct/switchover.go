@@ -0,0 +1,56 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package fakebpfstruct
+
+import "github.com/alexandremahdhaoui/ebpfstruct"
+
+var _ ebpfstruct.Switchover = &Switchover{}
+
+// NewSwitchover returns a Switchover calling switchover once committed.
+func NewSwitchover(switchover func()) *Switchover {
+	return &Switchover{
+		switchover: switchover,
+		Committed:  false,
+		Aborted:    false,
+	}
+}
+
+type Switchover struct {
+	switchover func()
+
+	// Committed is true if Commit() has been called successfully.
+	Committed bool
+	// Aborted is true if Abort() has been called before Commit().
+	Aborted bool
+}
+
+// Commit implements ebpfstruct.Switchover.
+func (s *Switchover) Commit() error {
+	if s.Committed || s.Aborted {
+		return ebpfstruct.ErrSwitchoverClosed
+	}
+	s.switchover()
+	s.Committed = true
+	return nil
+}
+
+// Abort implements ebpfstruct.Switchover.
+func (s *Switchover) Abort() {
+	if s.Committed || s.Aborted {
+		return
+	}
+	s.Aborted = true
+}
@@ -15,24 +15,43 @@
  */
 package fakebpfstruct
 
-import "github.com/alexandremahdhaoui/ebpfstruct"
+import (
+	"reflect"
+	"sync"
+
+	"github.com/alexandremahdhaoui/ebpfstruct"
+)
 
 var _ ebpfstruct.Variable[any] = &Variable[any]{}
 
 func NewVariable[T any]() *Variable[T] {
 	return &Variable[T]{
 		V:        *new(T),
+		Fields:   make(map[string]any),
 		doneCh:   make(chan struct{}),
+		doneOnce: &sync.Once{},
 		expector: expector{},
 	}
 }
 
 type Variable[T any] struct {
-	V      T
-	doneCh chan struct{}
+	V T
+	// Fields holds the values written by SetField & read by GetField,
+	// indexed by path. It is independent from V.
+	Fields   map[string]any
+	doneCh   chan struct{}
+	doneOnce *sync.Once
 	expector
 }
 
+// Get implements ebpfstruct.Variable.
+func (bv *Variable[T]) Get() (T, error) {
+	if err := bv.checkExpectation("Get"); err != nil {
+		return *new(T), err
+	}
+	return bv.V, nil
+}
+
 // Set implements ebpfstruct.Variable.
 func (bv *Variable[T]) Set(v T) error {
 	if err := bv.checkExpectation("Set"); err != nil {
@@ -42,6 +61,80 @@ func (bv *Variable[T]) Set(v T) error {
 	return nil
 }
 
+// Close implements ebpfstruct.Variable. If the expected error is nil, it closes
+// the channel returned by Done().
+func (bv *Variable[T]) Close() error {
+	if err := bv.checkExpectation("Close"); err != nil {
+		return err
+	}
+	bv.CloseDoneChannel()
+	return nil
+}
+
+// Update implements ebpfstruct.Variable.
+func (bv *Variable[T]) Update(fn func(T) T) error {
+	if err := bv.checkExpectation("Update"); err != nil {
+		return err
+	}
+	bv.V = fn(bv.V)
+	return nil
+}
+
+// CompareAndSwap implements ebpfstruct.Variable.
+func (bv *Variable[T]) CompareAndSwap(old, newValue T) (bool, error) {
+	if err := bv.checkExpectation("CompareAndSwap"); err != nil {
+		return false, err
+	}
+	if !reflect.DeepEqual(bv.V, old) {
+		return false, nil
+	}
+	bv.V = newValue
+	return true, nil
+}
+
+// Add implements ebpfstruct.Variable.
+func (bv *Variable[T]) Add(delta T) (T, error) {
+	if err := bv.checkExpectation("Add"); err != nil {
+		return bv.V, err
+	}
+	v, d := reflect.ValueOf(&bv.V).Elem(), reflect.ValueOf(delta)
+	switch {
+	case v.CanInt():
+		v.SetInt(v.Int() + d.Int())
+	case v.CanUint():
+		v.SetUint(v.Uint() + d.Uint())
+	default:
+		return bv.V, ebpfstruct.ErrNotAnInteger
+	}
+	return bv.V, nil
+}
+
+// GetField implements ebpfstruct.Variable.
+func (bv *Variable[T]) GetField(path string, out any) error {
+	if err := bv.checkExpectation("GetField"); err != nil {
+		return err
+	}
+	v, ok := bv.Fields[path]
+	if !ok {
+		return ebpfstruct.ErrFieldNotFound
+	}
+	rv, rout := reflect.ValueOf(v), reflect.ValueOf(out).Elem()
+	if !rv.Type().AssignableTo(rout.Type()) {
+		return ebpfstruct.ErrTypeMismatch
+	}
+	rout.Set(rv)
+	return nil
+}
+
+// SetField implements ebpfstruct.Variable.
+func (bv *Variable[T]) SetField(path string, v any) error {
+	if err := bv.checkExpectation("SetField"); err != nil {
+		return err
+	}
+	bv.Fields[path] = v
+	return nil
+}
+
 func (bv *Variable[T]) Done() <-chan struct{} {
 	return bv.doneCh
 }
@@ -50,5 +143,5 @@ func (bv *Variable[T]) Done() <-chan struct{} {
 // that the work done on behalf of this Variable[T] has been gracefully
 // terminated.
 func (bv *Variable[T]) CloseDoneChannel() {
-	close(bv.doneCh)
+	bv.doneOnce.Do(func() { close(bv.doneCh) })
 }
@@ -20,6 +20,7 @@
 package mockebpfstruct
 
 import (
+	"github.com/alexandremahdhaoui/ebpfstruct"
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -50,6 +51,50 @@ func (_m *MockArray[T]) EXPECT() *MockArray_Expecter[T] {
 	return &MockArray_Expecter[T]{mock: &_m.Mock}
 }
 
+// Close provides a mock function for the type MockArray
+func (_mock *MockArray[T]) Close() error {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Close")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func() error); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockArray_Close_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Close'
+type MockArray_Close_Call[T any] struct {
+	*mock.Call
+}
+
+// Close is a helper method to define mock.On call
+func (_e *MockArray_Expecter[T]) Close() *MockArray_Close_Call[T] {
+	return &MockArray_Close_Call[T]{Call: _e.mock.On("Close")}
+}
+
+func (_c *MockArray_Close_Call[T]) Run(run func()) *MockArray_Close_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockArray_Close_Call[T]) Return(err error) *MockArray_Close_Call[T] {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockArray_Close_Call[T]) RunAndReturn(run func() error) *MockArray_Close_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Done provides a mock function for the type MockArray
 func (_mock *MockArray[T]) Done() <-chan struct{} {
 	ret := _mock.Called()
@@ -96,6 +141,172 @@ func (_c *MockArray_Done_Call[T]) RunAndReturn(run func() <-chan struct{}) *Mock
 	return _c
 }
 
+// Get provides a mock function for the type MockArray
+func (_mock *MockArray[T]) Get() ([]T, error) {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Get")
+	}
+
+	var r0 []T
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func() ([]T, error)); ok {
+		return returnFunc()
+	}
+	if returnFunc, ok := ret.Get(0).(func() []T); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]T)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func() error); ok {
+		r1 = returnFunc()
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockArray_Get_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Get'
+type MockArray_Get_Call[T any] struct {
+	*mock.Call
+}
+
+// Get is a helper method to define mock.On call
+func (_e *MockArray_Expecter[T]) Get() *MockArray_Get_Call[T] {
+	return &MockArray_Get_Call[T]{Call: _e.mock.On("Get")}
+}
+
+func (_c *MockArray_Get_Call[T]) Run(run func()) *MockArray_Get_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockArray_Get_Call[T]) Return(vs []T, err error) *MockArray_Get_Call[T] {
+	_c.Call.Return(vs, err)
+	return _c
+}
+
+func (_c *MockArray_Get_Call[T]) RunAndReturn(run func() ([]T, error)) *MockArray_Get_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetPassive provides a mock function for the type MockArray
+func (_mock *MockArray[T]) GetPassive() ([]T, error) {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPassive")
+	}
+
+	var r0 []T
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func() ([]T, error)); ok {
+		return returnFunc()
+	}
+	if returnFunc, ok := ret.Get(0).(func() []T); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]T)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func() error); ok {
+		r1 = returnFunc()
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockArray_GetPassive_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetPassive'
+type MockArray_GetPassive_Call[T any] struct {
+	*mock.Call
+}
+
+// GetPassive is a helper method to define mock.On call
+func (_e *MockArray_Expecter[T]) GetPassive() *MockArray_GetPassive_Call[T] {
+	return &MockArray_GetPassive_Call[T]{Call: _e.mock.On("GetPassive")}
+}
+
+func (_c *MockArray_GetPassive_Call[T]) Run(run func()) *MockArray_GetPassive_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockArray_GetPassive_Call[T]) Return(vs []T, err error) *MockArray_GetPassive_Call[T] {
+	_c.Call.Return(vs, err)
+	return _c
+}
+
+func (_c *MockArray_GetPassive_Call[T]) RunAndReturn(run func() ([]T, error)) *MockArray_GetPassive_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PrepareSwitchover provides a mock function for the type MockArray
+func (_mock *MockArray[T]) PrepareSwitchover(values []T) (ebpfstruct.Switchover, error) {
+	ret := _mock.Called(values)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PrepareSwitchover")
+	}
+
+	var r0 ebpfstruct.Switchover
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func([]T) (ebpfstruct.Switchover, error)); ok {
+		return returnFunc(values)
+	}
+	if returnFunc, ok := ret.Get(0).(func([]T) ebpfstruct.Switchover); ok {
+		r0 = returnFunc(values)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(ebpfstruct.Switchover)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func([]T) error); ok {
+		r1 = returnFunc(values)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockArray_PrepareSwitchover_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PrepareSwitchover'
+type MockArray_PrepareSwitchover_Call[T any] struct {
+	*mock.Call
+}
+
+// PrepareSwitchover is a helper method to define mock.On call
+//   - values
+func (_e *MockArray_Expecter[T]) PrepareSwitchover(values interface{}) *MockArray_PrepareSwitchover_Call[T] {
+	return &MockArray_PrepareSwitchover_Call[T]{Call: _e.mock.On("PrepareSwitchover", values)}
+}
+
+func (_c *MockArray_PrepareSwitchover_Call[T]) Run(run func(values []T)) *MockArray_PrepareSwitchover_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].([]T))
+	})
+	return _c
+}
+
+func (_c *MockArray_PrepareSwitchover_Call[T]) Return(v ebpfstruct.Switchover, err error) *MockArray_PrepareSwitchover_Call[T] {
+	_c.Call.Return(v, err)
+	return _c
+}
+
+func (_c *MockArray_PrepareSwitchover_Call[T]) RunAndReturn(run func(values []T) (ebpfstruct.Switchover, error)) *MockArray_PrepareSwitchover_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Set provides a mock function for the type MockArray
 func (_mock *MockArray[T]) Set(values []T) error {
 	ret := _mock.Called(values)
@@ -142,31 +353,39 @@ func (_c *MockArray_Set_Call[T]) RunAndReturn(run func(values []T) error) *MockA
 }
 
 // SetAndDeferSwitchover provides a mock function for the type MockArray
-func (_mock *MockArray[T]) SetAndDeferSwitchover(values []T) (func(), error) {
+func (_mock *MockArray[T]) SetAndDeferSwitchover(values []T) (func() error, func(), error) {
 	ret := _mock.Called(values)
 
 	if len(ret) == 0 {
 		panic("no return value specified for SetAndDeferSwitchover")
 	}
 
-	var r0 func()
-	var r1 error
-	if returnFunc, ok := ret.Get(0).(func([]T) (func(), error)); ok {
+	var r0 func() error
+	var r1 func()
+	var r2 error
+	if returnFunc, ok := ret.Get(0).(func([]T) (func() error, func(), error)); ok {
 		return returnFunc(values)
 	}
-	if returnFunc, ok := ret.Get(0).(func([]T) func()); ok {
+	if returnFunc, ok := ret.Get(0).(func([]T) func() error); ok {
 		r0 = returnFunc(values)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(func())
+			r0 = ret.Get(0).(func() error)
 		}
 	}
-	if returnFunc, ok := ret.Get(1).(func([]T) error); ok {
+	if returnFunc, ok := ret.Get(1).(func([]T) func()); ok {
 		r1 = returnFunc(values)
 	} else {
-		r1 = ret.Error(1)
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(func())
+		}
 	}
-	return r0, r1
+	if returnFunc, ok := ret.Get(2).(func([]T) error); ok {
+		r2 = returnFunc(values)
+	} else {
+		r2 = ret.Error(2)
+	}
+	return r0, r1, r2
 }
 
 // MockArray_SetAndDeferSwitchover_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetAndDeferSwitchover'
@@ -187,12 +406,12 @@ func (_c *MockArray_SetAndDeferSwitchover_Call[T]) Run(run func(values []T)) *Mo
 	return _c
 }
 
-func (_c *MockArray_SetAndDeferSwitchover_Call[T]) Return(fn func(), err error) *MockArray_SetAndDeferSwitchover_Call[T] {
-	_c.Call.Return(fn, err)
+func (_c *MockArray_SetAndDeferSwitchover_Call[T]) Return(commit func() error, abort func(), err error) *MockArray_SetAndDeferSwitchover_Call[T] {
+	_c.Call.Return(commit, abort, err)
 	return _c
 }
 
-func (_c *MockArray_SetAndDeferSwitchover_Call[T]) RunAndReturn(run func(values []T) (func(), error)) *MockArray_SetAndDeferSwitchover_Call[T] {
+func (_c *MockArray_SetAndDeferSwitchover_Call[T]) RunAndReturn(run func(values []T) (func() error, func(), error)) *MockArray_SetAndDeferSwitchover_Call[T] {
 	_c.Call.Return(run)
 	return _c
 }
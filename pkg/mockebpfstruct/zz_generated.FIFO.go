@@ -20,6 +20,9 @@
 package mockebpfstruct
 
 import (
+	"context"
+
+	"github.com/alexandremahdhaoui/ebpfstruct"
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -50,6 +53,50 @@ func (_m *MockFIFO[T]) EXPECT() *MockFIFO_Expecter[T] {
 	return &MockFIFO_Expecter[T]{mock: &_m.Mock}
 }
 
+// Close provides a mock function for the type MockFIFO
+func (_mock *MockFIFO[T]) Close() error {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Close")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func() error); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockFIFO_Close_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Close'
+type MockFIFO_Close_Call[T any] struct {
+	*mock.Call
+}
+
+// Close is a helper method to define mock.On call
+func (_e *MockFIFO_Expecter[T]) Close() *MockFIFO_Close_Call[T] {
+	return &MockFIFO_Close_Call[T]{Call: _e.mock.On("Close")}
+}
+
+func (_c *MockFIFO_Close_Call[T]) Run(run func()) *MockFIFO_Close_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockFIFO_Close_Call[T]) Return(err error) *MockFIFO_Close_Call[T] {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockFIFO_Close_Call[T]) RunAndReturn(run func() error) *MockFIFO_Close_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Done provides a mock function for the type MockFIFO
 func (_mock *MockFIFO[T]) Done() <-chan struct{} {
 	ret := _mock.Called()
@@ -96,28 +143,106 @@ func (_c *MockFIFO_Done_Call[T]) RunAndReturn(run func() <-chan struct{}) *MockF
 	return _c
 }
 
-// Subscribe provides a mock function for the type MockFIFO
-func (_mock *MockFIFO[T]) Subscribe() (<-chan T, error) {
+// RecycleBatch provides a mock function for the type MockFIFO
+func (_mock *MockFIFO[T]) RecycleBatch(batch []T) {
+	_mock.Called(batch)
+	return
+}
+
+// MockFIFO_RecycleBatch_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RecycleBatch'
+type MockFIFO_RecycleBatch_Call[T any] struct {
+	*mock.Call
+}
+
+// RecycleBatch is a helper method to define mock.On call
+//   - batch
+func (_e *MockFIFO_Expecter[T]) RecycleBatch(batch interface{}) *MockFIFO_RecycleBatch_Call[T] {
+	return &MockFIFO_RecycleBatch_Call[T]{Call: _e.mock.On("RecycleBatch", batch)}
+}
+
+func (_c *MockFIFO_RecycleBatch_Call[T]) Run(run func(batch []T)) *MockFIFO_RecycleBatch_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].([]T))
+	})
+	return _c
+}
+
+func (_c *MockFIFO_RecycleBatch_Call[T]) Return() *MockFIFO_RecycleBatch_Call[T] {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockFIFO_RecycleBatch_Call[T]) RunAndReturn(run func(batch []T)) *MockFIFO_RecycleBatch_Call[T] {
+	_c.Run(run)
+	return _c
+}
+
+// Stats provides a mock function for the type MockFIFO
+func (_mock *MockFIFO[T]) Stats() ebpfstruct.FIFOStats {
 	ret := _mock.Called()
 
+	if len(ret) == 0 {
+		panic("no return value specified for Stats")
+	}
+
+	var r0 ebpfstruct.FIFOStats
+	if returnFunc, ok := ret.Get(0).(func() ebpfstruct.FIFOStats); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Get(0).(ebpfstruct.FIFOStats)
+	}
+	return r0
+}
+
+// MockFIFO_Stats_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Stats'
+type MockFIFO_Stats_Call[T any] struct {
+	*mock.Call
+}
+
+// Stats is a helper method to define mock.On call
+func (_e *MockFIFO_Expecter[T]) Stats() *MockFIFO_Stats_Call[T] {
+	return &MockFIFO_Stats_Call[T]{Call: _e.mock.On("Stats")}
+}
+
+func (_c *MockFIFO_Stats_Call[T]) Run(run func()) *MockFIFO_Stats_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockFIFO_Stats_Call[T]) Return(fIFOStats ebpfstruct.FIFOStats) *MockFIFO_Stats_Call[T] {
+	_c.Call.Return(fIFOStats)
+	return _c
+}
+
+func (_c *MockFIFO_Stats_Call[T]) RunAndReturn(run func() ebpfstruct.FIFOStats) *MockFIFO_Stats_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Subscribe provides a mock function for the type MockFIFO
+func (_mock *MockFIFO[T]) Subscribe(ctx context.Context) (<-chan T, error) {
+	ret := _mock.Called(ctx)
+
 	if len(ret) == 0 {
 		panic("no return value specified for Subscribe")
 	}
 
 	var r0 <-chan T
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func() (<-chan T, error)); ok {
-		return returnFunc()
+	if returnFunc, ok := ret.Get(0).(func(context.Context) (<-chan T, error)); ok {
+		return returnFunc(ctx)
 	}
-	if returnFunc, ok := ret.Get(0).(func() <-chan T); ok {
-		r0 = returnFunc()
+	if returnFunc, ok := ret.Get(0).(func(context.Context) <-chan T); ok {
+		r0 = returnFunc(ctx)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(<-chan T)
 		}
 	}
-	if returnFunc, ok := ret.Get(1).(func() error); ok {
-		r1 = returnFunc()
+	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = returnFunc(ctx)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -130,13 +255,14 @@ type MockFIFO_Subscribe_Call[T any] struct {
 }
 
 // Subscribe is a helper method to define mock.On call
-func (_e *MockFIFO_Expecter[T]) Subscribe() *MockFIFO_Subscribe_Call[T] {
-	return &MockFIFO_Subscribe_Call[T]{Call: _e.mock.On("Subscribe")}
+//   - ctx
+func (_e *MockFIFO_Expecter[T]) Subscribe(ctx interface{}) *MockFIFO_Subscribe_Call[T] {
+	return &MockFIFO_Subscribe_Call[T]{Call: _e.mock.On("Subscribe", ctx)}
 }
 
-func (_c *MockFIFO_Subscribe_Call[T]) Run(run func()) *MockFIFO_Subscribe_Call[T] {
+func (_c *MockFIFO_Subscribe_Call[T]) Run(run func(ctx context.Context)) *MockFIFO_Subscribe_Call[T] {
 	_c.Call.Run(func(args mock.Arguments) {
-		run()
+		run(args[0].(context.Context))
 	})
 	return _c
 }
@@ -146,7 +272,119 @@ func (_c *MockFIFO_Subscribe_Call[T]) Return(vCh <-chan T, err error) *MockFIFO_
 	return _c
 }
 
-func (_c *MockFIFO_Subscribe_Call[T]) RunAndReturn(run func() (<-chan T, error)) *MockFIFO_Subscribe_Call[T] {
+func (_c *MockFIFO_Subscribe_Call[T]) RunAndReturn(run func(ctx context.Context) (<-chan T, error)) *MockFIFO_Subscribe_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SubscribeBatch provides a mock function for the type MockFIFO
+func (_mock *MockFIFO[T]) SubscribeBatch(ctx context.Context) (<-chan []T, error) {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SubscribeBatch")
+	}
+
+	var r0 <-chan []T
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) (<-chan []T, error)); ok {
+		return returnFunc(ctx)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context) <-chan []T); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan []T)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = returnFunc(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockFIFO_SubscribeBatch_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SubscribeBatch'
+type MockFIFO_SubscribeBatch_Call[T any] struct {
+	*mock.Call
+}
+
+// SubscribeBatch is a helper method to define mock.On call
+//   - ctx
+func (_e *MockFIFO_Expecter[T]) SubscribeBatch(ctx interface{}) *MockFIFO_SubscribeBatch_Call[T] {
+	return &MockFIFO_SubscribeBatch_Call[T]{Call: _e.mock.On("SubscribeBatch", ctx)}
+}
+
+func (_c *MockFIFO_SubscribeBatch_Call[T]) Run(run func(ctx context.Context)) *MockFIFO_SubscribeBatch_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockFIFO_SubscribeBatch_Call[T]) Return(vCh <-chan []T, err error) *MockFIFO_SubscribeBatch_Call[T] {
+	_c.Call.Return(vCh, err)
+	return _c
+}
+
+func (_c *MockFIFO_SubscribeBatch_Call[T]) RunAndReturn(run func(ctx context.Context) (<-chan []T, error)) *MockFIFO_SubscribeBatch_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SubscribeWithErrors provides a mock function for the type MockFIFO
+func (_mock *MockFIFO[T]) SubscribeWithErrors(ctx context.Context) (<-chan ebpfstruct.FIFOResult[T], error) {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SubscribeWithErrors")
+	}
+
+	var r0 <-chan ebpfstruct.FIFOResult[T]
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) (<-chan ebpfstruct.FIFOResult[T], error)); ok {
+		return returnFunc(ctx)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context) <-chan ebpfstruct.FIFOResult[T]); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan ebpfstruct.FIFOResult[T])
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = returnFunc(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockFIFO_SubscribeWithErrors_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SubscribeWithErrors'
+type MockFIFO_SubscribeWithErrors_Call[T any] struct {
+	*mock.Call
+}
+
+// SubscribeWithErrors is a helper method to define mock.On call
+//   - ctx
+func (_e *MockFIFO_Expecter[T]) SubscribeWithErrors(ctx interface{}) *MockFIFO_SubscribeWithErrors_Call[T] {
+	return &MockFIFO_SubscribeWithErrors_Call[T]{Call: _e.mock.On("SubscribeWithErrors", ctx)}
+}
+
+func (_c *MockFIFO_SubscribeWithErrors_Call[T]) Run(run func(ctx context.Context)) *MockFIFO_SubscribeWithErrors_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockFIFO_SubscribeWithErrors_Call[T]) Return(vCh <-chan ebpfstruct.FIFOResult[T], err error) *MockFIFO_SubscribeWithErrors_Call[T] {
+	_c.Call.Return(vCh, err)
+	return _c
+}
+
+func (_c *MockFIFO_SubscribeWithErrors_Call[T]) RunAndReturn(run func(ctx context.Context) (<-chan ebpfstruct.FIFOResult[T], error)) *MockFIFO_SubscribeWithErrors_Call[T] {
 	_c.Call.Return(run)
 	return _c
 }
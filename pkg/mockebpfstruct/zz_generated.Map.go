@@ -20,7 +20,9 @@
 package mockebpfstruct
 
 import (
+	"github.com/alexandremahdhaoui/ebpfstruct"
 	mock "github.com/stretchr/testify/mock"
+	"iter"
 )
 
 // NewMockMap creates a new instance of MockMap. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
@@ -140,6 +142,50 @@ func (_c *MockMap_BatchUpdate_Call[K, V]) RunAndReturn(run func(kv map[K]V) erro
 	return _c
 }
 
+// Close provides a mock function for the type MockMap
+func (_mock *MockMap[K, V]) Close() error {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Close")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func() error); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockMap_Close_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Close'
+type MockMap_Close_Call[K comparable, V any] struct {
+	*mock.Call
+}
+
+// Close is a helper method to define mock.On call
+func (_e *MockMap_Expecter[K, V]) Close() *MockMap_Close_Call[K, V] {
+	return &MockMap_Close_Call[K, V]{Call: _e.mock.On("Close")}
+}
+
+func (_c *MockMap_Close_Call[K, V]) Run(run func()) *MockMap_Close_Call[K, V] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockMap_Close_Call[K, V]) Return(err error) *MockMap_Close_Call[K, V] {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockMap_Close_Call[K, V]) RunAndReturn(run func() error) *MockMap_Close_Call[K, V] {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Done provides a mock function for the type MockMap
 func (_mock *MockMap[K, V]) Done() <-chan struct{} {
 	ret := _mock.Called()
@@ -186,6 +232,234 @@ func (_c *MockMap_Done_Call[K, V]) RunAndReturn(run func() <-chan struct{}) *Moc
 	return _c
 }
 
+// GetAll provides a mock function for the type MockMap
+func (_mock *MockMap[K, V]) GetAll() (map[K]V, error) {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetAll")
+	}
+
+	var r0 map[K]V
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func() (map[K]V, error)); ok {
+		return returnFunc()
+	}
+	if returnFunc, ok := ret.Get(0).(func() map[K]V); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[K]V)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func() error); ok {
+		r1 = returnFunc()
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockMap_GetAll_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetAll'
+type MockMap_GetAll_Call[K comparable, V any] struct {
+	*mock.Call
+}
+
+// GetAll is a helper method to define mock.On call
+func (_e *MockMap_Expecter[K, V]) GetAll() *MockMap_GetAll_Call[K, V] {
+	return &MockMap_GetAll_Call[K, V]{Call: _e.mock.On("GetAll")}
+}
+
+func (_c *MockMap_GetAll_Call[K, V]) Run(run func()) *MockMap_GetAll_Call[K, V] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockMap_GetAll_Call[K, V]) Return(vs map[K]V, err error) *MockMap_GetAll_Call[K, V] {
+	_c.Call.Return(vs, err)
+	return _c
+}
+
+func (_c *MockMap_GetAll_Call[K, V]) RunAndReturn(run func() (map[K]V, error)) *MockMap_GetAll_Call[K, V] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Iterate provides a mock function for the type MockMap
+func (_mock *MockMap[K, V]) Iterate() (iter.Seq2[K, V], func() error) {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Iterate")
+	}
+
+	var r0 iter.Seq2[K, V]
+	var r1 func() error
+	if returnFunc, ok := ret.Get(0).(func() (iter.Seq2[K, V], func() error)); ok {
+		return returnFunc()
+	}
+	if returnFunc, ok := ret.Get(0).(func() iter.Seq2[K, V]); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(iter.Seq2[K, V])
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func() func() error); ok {
+		r1 = returnFunc()
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(func() error)
+		}
+	}
+	return r0, r1
+}
+
+// MockMap_Iterate_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Iterate'
+type MockMap_Iterate_Call[K comparable, V any] struct {
+	*mock.Call
+}
+
+// Iterate is a helper method to define mock.On call
+func (_e *MockMap_Expecter[K, V]) Iterate() *MockMap_Iterate_Call[K, V] {
+	return &MockMap_Iterate_Call[K, V]{Call: _e.mock.On("Iterate")}
+}
+
+func (_c *MockMap_Iterate_Call[K, V]) Run(run func()) *MockMap_Iterate_Call[K, V] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockMap_Iterate_Call[K, V]) Return(v iter.Seq2[K, V], fn func() error) *MockMap_Iterate_Call[K, V] {
+	_c.Call.Return(v, fn)
+	return _c
+}
+
+func (_c *MockMap_Iterate_Call[K, V]) RunAndReturn(run func() (iter.Seq2[K, V], func() error)) *MockMap_Iterate_Call[K, V] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Lookup provides a mock function for the type MockMap
+func (_mock *MockMap[K, V]) Lookup(k K) (V, bool, error) {
+	ret := _mock.Called(k)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Lookup")
+	}
+
+	var r0 V
+	var r1 bool
+	var r2 error
+	if returnFunc, ok := ret.Get(0).(func(K) (V, bool, error)); ok {
+		return returnFunc(k)
+	}
+	if returnFunc, ok := ret.Get(0).(func(K) V); ok {
+		r0 = returnFunc(k)
+	} else {
+		r0 = ret.Get(0).(V)
+	}
+	if returnFunc, ok := ret.Get(1).(func(K) bool); ok {
+		r1 = returnFunc(k)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+	if returnFunc, ok := ret.Get(2).(func(K) error); ok {
+		r2 = returnFunc(k)
+	} else {
+		r2 = ret.Error(2)
+	}
+	return r0, r1, r2
+}
+
+// MockMap_Lookup_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Lookup'
+type MockMap_Lookup_Call[K comparable, V any] struct {
+	*mock.Call
+}
+
+// Lookup is a helper method to define mock.On call
+//   - k
+func (_e *MockMap_Expecter[K, V]) Lookup(k interface{}) *MockMap_Lookup_Call[K, V] {
+	return &MockMap_Lookup_Call[K, V]{Call: _e.mock.On("Lookup", k)}
+}
+
+func (_c *MockMap_Lookup_Call[K, V]) Run(run func(k K)) *MockMap_Lookup_Call[K, V] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(K))
+	})
+	return _c
+}
+
+func (_c *MockMap_Lookup_Call[K, V]) Return(v V, b bool, err error) *MockMap_Lookup_Call[K, V] {
+	_c.Call.Return(v, b, err)
+	return _c
+}
+
+func (_c *MockMap_Lookup_Call[K, V]) RunAndReturn(run func(k K) (V, bool, error)) *MockMap_Lookup_Call[K, V] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PrepareSwitchover provides a mock function for the type MockMap
+func (_mock *MockMap[K, V]) PrepareSwitchover(newMap map[K]V) (ebpfstruct.Switchover, error) {
+	ret := _mock.Called(newMap)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PrepareSwitchover")
+	}
+
+	var r0 ebpfstruct.Switchover
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(map[K]V) (ebpfstruct.Switchover, error)); ok {
+		return returnFunc(newMap)
+	}
+	if returnFunc, ok := ret.Get(0).(func(map[K]V) ebpfstruct.Switchover); ok {
+		r0 = returnFunc(newMap)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(ebpfstruct.Switchover)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(map[K]V) error); ok {
+		r1 = returnFunc(newMap)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockMap_PrepareSwitchover_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PrepareSwitchover'
+type MockMap_PrepareSwitchover_Call[K comparable, V any] struct {
+	*mock.Call
+}
+
+// PrepareSwitchover is a helper method to define mock.On call
+//   - newMap
+func (_e *MockMap_Expecter[K, V]) PrepareSwitchover(newMap interface{}) *MockMap_PrepareSwitchover_Call[K, V] {
+	return &MockMap_PrepareSwitchover_Call[K, V]{Call: _e.mock.On("PrepareSwitchover", newMap)}
+}
+
+func (_c *MockMap_PrepareSwitchover_Call[K, V]) Run(run func(newMap map[K]V)) *MockMap_PrepareSwitchover_Call[K, V] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(map[K]V))
+	})
+	return _c
+}
+
+func (_c *MockMap_PrepareSwitchover_Call[K, V]) Return(v ebpfstruct.Switchover, err error) *MockMap_PrepareSwitchover_Call[K, V] {
+	_c.Call.Return(v, err)
+	return _c
+}
+
+func (_c *MockMap_PrepareSwitchover_Call[K, V]) RunAndReturn(run func(newMap map[K]V) (ebpfstruct.Switchover, error)) *MockMap_PrepareSwitchover_Call[K, V] {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Set provides a mock function for the type MockMap
 func (_mock *MockMap[K, V]) Set(newMap map[K]V) error {
 	ret := _mock.Called(newMap)
@@ -232,31 +506,39 @@ func (_c *MockMap_Set_Call[K, V]) RunAndReturn(run func(newMap map[K]V) error) *
 }
 
 // SetAndDeferSwitchover provides a mock function for the type MockMap
-func (_mock *MockMap[K, V]) SetAndDeferSwitchover(newMap map[K]V) (func(), error) {
+func (_mock *MockMap[K, V]) SetAndDeferSwitchover(newMap map[K]V) (func() error, func(), error) {
 	ret := _mock.Called(newMap)
 
 	if len(ret) == 0 {
 		panic("no return value specified for SetAndDeferSwitchover")
 	}
 
-	var r0 func()
-	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(map[K]V) (func(), error)); ok {
+	var r0 func() error
+	var r1 func()
+	var r2 error
+	if returnFunc, ok := ret.Get(0).(func(map[K]V) (func() error, func(), error)); ok {
 		return returnFunc(newMap)
 	}
-	if returnFunc, ok := ret.Get(0).(func(map[K]V) func()); ok {
+	if returnFunc, ok := ret.Get(0).(func(map[K]V) func() error); ok {
 		r0 = returnFunc(newMap)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(func())
+			r0 = ret.Get(0).(func() error)
 		}
 	}
-	if returnFunc, ok := ret.Get(1).(func(map[K]V) error); ok {
+	if returnFunc, ok := ret.Get(1).(func(map[K]V) func()); ok {
 		r1 = returnFunc(newMap)
 	} else {
-		r1 = ret.Error(1)
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(func())
+		}
 	}
-	return r0, r1
+	if returnFunc, ok := ret.Get(2).(func(map[K]V) error); ok {
+		r2 = returnFunc(newMap)
+	} else {
+		r2 = ret.Error(2)
+	}
+	return r0, r1, r2
 }
 
 // MockMap_SetAndDeferSwitchover_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetAndDeferSwitchover'
@@ -277,12 +559,12 @@ func (_c *MockMap_SetAndDeferSwitchover_Call[K, V]) Run(run func(newMap map[K]V)
 	return _c
 }
 
-func (_c *MockMap_SetAndDeferSwitchover_Call[K, V]) Return(fn func(), err error) *MockMap_SetAndDeferSwitchover_Call[K, V] {
-	_c.Call.Return(fn, err)
+func (_c *MockMap_SetAndDeferSwitchover_Call[K, V]) Return(commit func() error, abort func(), err error) *MockMap_SetAndDeferSwitchover_Call[K, V] {
+	_c.Call.Return(commit, abort, err)
 	return _c
 }
 
-func (_c *MockMap_SetAndDeferSwitchover_Call[K, V]) RunAndReturn(run func(newMap map[K]V) (func(), error)) *MockMap_SetAndDeferSwitchover_Call[K, V] {
+func (_c *MockMap_SetAndDeferSwitchover_Call[K, V]) RunAndReturn(run func(newMap map[K]V) (func() error, func(), error)) *MockMap_SetAndDeferSwitchover_Call[K, V] {
 	_c.Call.Return(run)
 	return _c
 }
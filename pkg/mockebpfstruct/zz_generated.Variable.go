@@ -50,6 +50,161 @@ func (_m *MockVariable[T]) EXPECT() *MockVariable_Expecter[T] {
 	return &MockVariable_Expecter[T]{mock: &_m.Mock}
 }
 
+// Add provides a mock function for the type MockVariable
+func (_mock *MockVariable[T]) Add(delta T) (T, error) {
+	ret := _mock.Called(delta)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Add")
+	}
+
+	var r0 T
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(T) (T, error)); ok {
+		return returnFunc(delta)
+	}
+	if returnFunc, ok := ret.Get(0).(func(T) T); ok {
+		r0 = returnFunc(delta)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(T)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(T) error); ok {
+		r1 = returnFunc(delta)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockVariable_Add_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Add'
+type MockVariable_Add_Call[T any] struct {
+	*mock.Call
+}
+
+// Add is a helper method to define mock.On call
+//   - delta
+func (_e *MockVariable_Expecter[T]) Add(delta interface{}) *MockVariable_Add_Call[T] {
+	return &MockVariable_Add_Call[T]{Call: _e.mock.On("Add", delta)}
+}
+
+func (_c *MockVariable_Add_Call[T]) Run(run func(delta T)) *MockVariable_Add_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(T))
+	})
+	return _c
+}
+
+func (_c *MockVariable_Add_Call[T]) Return(v T, err error) *MockVariable_Add_Call[T] {
+	_c.Call.Return(v, err)
+	return _c
+}
+
+func (_c *MockVariable_Add_Call[T]) RunAndReturn(run func(delta T) (T, error)) *MockVariable_Add_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Close provides a mock function for the type MockVariable
+func (_mock *MockVariable[T]) Close() error {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Close")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func() error); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockVariable_Close_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Close'
+type MockVariable_Close_Call[T any] struct {
+	*mock.Call
+}
+
+// Close is a helper method to define mock.On call
+func (_e *MockVariable_Expecter[T]) Close() *MockVariable_Close_Call[T] {
+	return &MockVariable_Close_Call[T]{Call: _e.mock.On("Close")}
+}
+
+func (_c *MockVariable_Close_Call[T]) Run(run func()) *MockVariable_Close_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockVariable_Close_Call[T]) Return(err error) *MockVariable_Close_Call[T] {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockVariable_Close_Call[T]) RunAndReturn(run func() error) *MockVariable_Close_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CompareAndSwap provides a mock function for the type MockVariable
+func (_mock *MockVariable[T]) CompareAndSwap(old T, newValue T) (bool, error) {
+	ret := _mock.Called(old, newValue)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CompareAndSwap")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(T, T) (bool, error)); ok {
+		return returnFunc(old, newValue)
+	}
+	if returnFunc, ok := ret.Get(0).(func(T, T) bool); ok {
+		r0 = returnFunc(old, newValue)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(T, T) error); ok {
+		r1 = returnFunc(old, newValue)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockVariable_CompareAndSwap_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CompareAndSwap'
+type MockVariable_CompareAndSwap_Call[T any] struct {
+	*mock.Call
+}
+
+// CompareAndSwap is a helper method to define mock.On call
+//   - old
+//   - newValue
+func (_e *MockVariable_Expecter[T]) CompareAndSwap(old interface{}, newValue interface{}) *MockVariable_CompareAndSwap_Call[T] {
+	return &MockVariable_CompareAndSwap_Call[T]{Call: _e.mock.On("CompareAndSwap", old, newValue)}
+}
+
+func (_c *MockVariable_CompareAndSwap_Call[T]) Run(run func(old T, newValue T)) *MockVariable_CompareAndSwap_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(T), args[1].(T))
+	})
+	return _c
+}
+
+func (_c *MockVariable_CompareAndSwap_Call[T]) Return(b bool, err error) *MockVariable_CompareAndSwap_Call[T] {
+	_c.Call.Return(b, err)
+	return _c
+}
+
+func (_c *MockVariable_CompareAndSwap_Call[T]) RunAndReturn(run func(old T, newValue T) (bool, error)) *MockVariable_CompareAndSwap_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Done provides a mock function for the type MockVariable
 func (_mock *MockVariable[T]) Done() <-chan struct{} {
 	ret := _mock.Called()
@@ -96,6 +251,107 @@ func (_c *MockVariable_Done_Call[T]) RunAndReturn(run func() <-chan struct{}) *M
 	return _c
 }
 
+// Get provides a mock function for the type MockVariable
+func (_mock *MockVariable[T]) Get() (T, error) {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Get")
+	}
+
+	var r0 T
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func() (T, error)); ok {
+		return returnFunc()
+	}
+	if returnFunc, ok := ret.Get(0).(func() T); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(T)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func() error); ok {
+		r1 = returnFunc()
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockVariable_Get_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Get'
+type MockVariable_Get_Call[T any] struct {
+	*mock.Call
+}
+
+// Get is a helper method to define mock.On call
+func (_e *MockVariable_Expecter[T]) Get() *MockVariable_Get_Call[T] {
+	return &MockVariable_Get_Call[T]{Call: _e.mock.On("Get")}
+}
+
+func (_c *MockVariable_Get_Call[T]) Run(run func()) *MockVariable_Get_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockVariable_Get_Call[T]) Return(v T, err error) *MockVariable_Get_Call[T] {
+	_c.Call.Return(v, err)
+	return _c
+}
+
+func (_c *MockVariable_Get_Call[T]) RunAndReturn(run func() (T, error)) *MockVariable_Get_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetField provides a mock function for the type MockVariable
+func (_mock *MockVariable[T]) GetField(path string, out any) error {
+	ret := _mock.Called(path, out)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetField")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(string, any) error); ok {
+		r0 = returnFunc(path, out)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockVariable_GetField_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetField'
+type MockVariable_GetField_Call[T any] struct {
+	*mock.Call
+}
+
+// GetField is a helper method to define mock.On call
+//   - path
+//   - out
+func (_e *MockVariable_Expecter[T]) GetField(path interface{}, out interface{}) *MockVariable_GetField_Call[T] {
+	return &MockVariable_GetField_Call[T]{Call: _e.mock.On("GetField", path, out)}
+}
+
+func (_c *MockVariable_GetField_Call[T]) Run(run func(path string, out any)) *MockVariable_GetField_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1])
+	})
+	return _c
+}
+
+func (_c *MockVariable_GetField_Call[T]) Return(err error) *MockVariable_GetField_Call[T] {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockVariable_GetField_Call[T]) RunAndReturn(run func(path string, out any) error) *MockVariable_GetField_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Set provides a mock function for the type MockVariable
 func (_mock *MockVariable[T]) Set(v T) error {
 	ret := _mock.Called(v)
@@ -140,3 +396,94 @@ func (_c *MockVariable_Set_Call[T]) RunAndReturn(run func(v T) error) *MockVaria
 	_c.Call.Return(run)
 	return _c
 }
+
+// SetField provides a mock function for the type MockVariable
+func (_mock *MockVariable[T]) SetField(path string, v any) error {
+	ret := _mock.Called(path, v)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetField")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(string, any) error); ok {
+		r0 = returnFunc(path, v)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockVariable_SetField_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetField'
+type MockVariable_SetField_Call[T any] struct {
+	*mock.Call
+}
+
+// SetField is a helper method to define mock.On call
+//   - path
+//   - v
+func (_e *MockVariable_Expecter[T]) SetField(path interface{}, v interface{}) *MockVariable_SetField_Call[T] {
+	return &MockVariable_SetField_Call[T]{Call: _e.mock.On("SetField", path, v)}
+}
+
+func (_c *MockVariable_SetField_Call[T]) Run(run func(path string, v any)) *MockVariable_SetField_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1])
+	})
+	return _c
+}
+
+func (_c *MockVariable_SetField_Call[T]) Return(err error) *MockVariable_SetField_Call[T] {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockVariable_SetField_Call[T]) RunAndReturn(run func(path string, v any) error) *MockVariable_SetField_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Update provides a mock function for the type MockVariable
+func (_mock *MockVariable[T]) Update(fn func(T) T) error {
+	ret := _mock.Called(fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Update")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(func(T) T) error); ok {
+		r0 = returnFunc(fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockVariable_Update_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Update'
+type MockVariable_Update_Call[T any] struct {
+	*mock.Call
+}
+
+// Update is a helper method to define mock.On call
+//   - fn
+func (_e *MockVariable_Expecter[T]) Update(fn interface{}) *MockVariable_Update_Call[T] {
+	return &MockVariable_Update_Call[T]{Call: _e.mock.On("Update", fn)}
+}
+
+func (_c *MockVariable_Update_Call[T]) Run(run func(fn func(T) T)) *MockVariable_Update_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(func(T) T))
+	})
+	return _c
+}
+
+func (_c *MockVariable_Update_Call[T]) Return(err error) *MockVariable_Update_Call[T] {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockVariable_Update_Call[T]) RunAndReturn(run func(fn func(T) T) error) *MockVariable_Update_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
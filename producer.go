@@ -0,0 +1,316 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/alexandremahdhaoui/tooling/pkg/flaterrors"
+	"golang.org/x/sys/unix"
+
+	"github.com/cilium/ebpf"
+)
+
+var (
+	ErrCreatingNewProducer = errors.New("creating new producer")
+	ErrPublishing          = errors.New("publishing to producer")
+	ErrProducerClosed      = errors.New("producer is closed")
+	ErrProducerFull        = errors.New("user ring buffer is full")
+	ErrRecordTooLarge      = errors.New("record is too large for user ring buffer")
+	ErrClosingProducer     = errors.New("closing producer")
+)
+
+const (
+	// userRingbufHdrSize is the size of a record header in a bpf ring
+	// buffer, i.e. `struct { __u32 len; __u32 pad; }`.
+	userRingbufHdrSize = 8
+	// userRingbufBusyBit is set in the header of a reserved record that
+	// has not been committed yet.
+	userRingbufBusyBit = 1 << 31
+	// userRingbufDiscardBit is set in the header of a discarded record.
+	userRingbufDiscardBit = 1 << 30
+)
+
+// -------------------------------------------------------------------
+// -- PRODUCER
+// -------------------------------------------------------------------
+
+// Producer[T] can be used to send structured notifications from userspace
+// to a bpf program through a BPF_MAP_TYPE_USER_RINGBUF. The bpf program
+// consumes them by calling bpf_user_ringbuf_drain().
+//
+// Values are encoded with encoding/binary in native endianness, i.e. the
+// same way FIFO[T] decodes records.
+//
+// Generics constraints:
+// - T must be a **struct**.
+// - T must not be a pointer.
+// - T must not be an interface.
+//
+// Notes:
+//   - Producer is thread-safe.
+type Producer[T any] interface {
+	// Done returns a channel that's closed when work done on behalf of this
+	// interface has been gracefully terminated, i.e. after the Producer has
+	// been closed.
+	Done() <-chan struct{}
+
+	// Publish sends v to the bpf program.
+	//
+	// Publish returns ErrProducerFull if the user ring buffer does not have
+	// enough space left for v.
+	Publish(v T) error
+
+	// PublishBatch sends all values to the bpf program, or none of them.
+	//
+	// PublishBatch returns ErrProducerFull if the user ring buffer does not
+	// have enough space left for all values.
+	PublishBatch(values []T) error
+
+	// Close unmaps the user ring buffer. Subsequent calls to Publish return
+	// ErrProducerClosed.
+	//
+	// Close can be called multiple times.
+	Close() error
+}
+
+type bpfProducer[T any] struct {
+	// mu guards reservations and the mapped memory.
+	mu     *sync.Mutex
+	closed bool
+
+	// consumerMem is the read-only consumer page.
+	consumerMem []byte
+	// producerMem is the producer page followed by the data pages, mapped
+	// twice in a row to allow writing records that wrap around.
+	producerMem []byte
+	consumerPos *uintptr
+	producerPos *uintptr
+	data        []byte
+	mask        uintptr
+
+	stopOnce *sync.Once
+	stopErr  error
+	// terminatedCh is closed once the Producer has been closed.
+	terminatedCh chan struct{}
+}
+
+// NewProducer creates a Producer[T] writing to a BPF_MAP_TYPE_USER_RINGBUF.
+//
+// Generics constraints:
+// - T must be a **struct**.
+// - T must not be a pointer.
+// - T must not be an interface.
+//
+// doneCh is a channel used to notify the bpf data structures or bpf
+// program has been closed and they can no longer be used. Closing doneCh
+// closes the Producer.
+func NewProducer[T any](userRingbufMap *ebpf.Map, doneCh <-chan struct{}) (Producer[T], error) {
+	if userRingbufMap == nil {
+		return nil, flaterrors.Join(ErrEBPFObjectsMustNotBeNil, ErrCreatingNewProducer)
+	}
+
+	if typ := userRingbufMap.Type(); typ != ebpf.UserRingbuf {
+		return nil, flaterrors.Join(
+			fmt.Errorf("map type must be %s; got %s", ebpf.UserRingbuf, typ),
+			ErrCreatingNewProducer,
+		)
+	}
+
+	if err := validateFixedSize[T]("user ring buffer: record"); err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewProducer)
+	}
+
+	p, err := newProducer[T](userRingbufMap, doneCh)
+	if err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewProducer)
+	}
+
+	return p, nil
+}
+
+// newProducer memory-maps the user ring buffer userRingbufMap.
+func newProducer[T any](userRingbufMap *ebpf.Map, doneCh <-chan struct{}) (*bpfProducer[T], error) {
+	pageSize := os.Getpagesize()
+	size := int(userRingbufMap.MaxEntries())
+	fd := userRingbufMap.FD()
+
+	consumerMem, err := unix.Mmap(fd, 0, pageSize, unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("mmap consumer page: %w", err)
+	}
+
+	producerMem, err := unix.Mmap(
+		fd,
+		int64(pageSize),
+		pageSize+2*size,
+		unix.PROT_READ|unix.PROT_WRITE,
+		unix.MAP_SHARED,
+	)
+	if err != nil {
+		_ = unix.Munmap(consumerMem)
+		return nil, fmt.Errorf("mmap producer & data pages: %w", err)
+	}
+
+	p := &bpfProducer[T]{
+		mu:           &sync.Mutex{},
+		closed:       false,
+		consumerMem:  consumerMem,
+		producerMem:  producerMem,
+		consumerPos:  (*uintptr)(unsafe.Pointer(&consumerMem[0])),
+		producerPos:  (*uintptr)(unsafe.Pointer(&producerMem[0])),
+		data:         producerMem[pageSize:],
+		mask:         uintptr(size - 1),
+		stopOnce:     &sync.Once{},
+		terminatedCh: make(chan struct{}),
+	}
+
+	go p.closeOnSignal(doneCh)
+
+	return p, nil
+}
+
+func (p *bpfProducer[T]) Done() <-chan struct{} {
+	return p.terminatedCh
+}
+
+func (p *bpfProducer[T]) Publish(v T) error {
+	return p.PublishBatch([]T{v})
+}
+
+func (p *bpfProducer[T]) PublishBatch(values []T) error {
+	samples := make([][]byte, 0, len(values))
+	for _, v := range values {
+		b, err := binary.Append(nil, binary.NativeEndian, v)
+		if err != nil {
+			return flaterrors.Join(err, ErrPublishing)
+		}
+		samples = append(samples, b)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return flaterrors.Join(ErrProducerClosed, ErrPublishing)
+	}
+
+	hdrs := make([]*uint32, 0, len(samples))
+	for _, sample := range samples {
+		hdr, buf, err := p.reserve(uint32(len(sample)))
+		if err != nil {
+			// Discard reserved records to publish all values or none.
+			for _, hdr := range hdrs {
+				commitUserRingbufRecord(hdr, true)
+			}
+			return flaterrors.Join(err, ErrPublishing)
+		}
+
+		copy(buf, sample)
+		hdrs = append(hdrs, hdr)
+	}
+
+	for _, hdr := range hdrs {
+		commitUserRingbufRecord(hdr, false)
+	}
+
+	return nil
+}
+
+func (p *bpfProducer[T]) Close() error {
+	return p.stop()
+}
+
+// reserve reserves a record of size bytes in the user ring buffer. It
+// returns the header of the record, which must be committed, and the
+// buffer the record must be written to. p.mu must be held.
+func (p *bpfProducer[T]) reserve(size uint32) (*uint32, []byte, error) {
+	if size&(userRingbufBusyBit|userRingbufDiscardBit) != 0 {
+		return nil, nil, ErrRecordTooLarge
+	}
+
+	consumerPos := atomic.LoadUintptr(p.consumerPos)
+	producerPos := atomic.LoadUintptr(p.producerPos)
+
+	maxSize := p.mask + 1
+	availSize := maxSize - (producerPos - consumerPos)
+	// Round up total size to a multiple of 8.
+	totalSize := (uintptr(size) + userRingbufHdrSize + 7) / 8 * 8
+
+	if totalSize > maxSize {
+		return nil, nil, ErrRecordTooLarge
+	}
+
+	if availSize < totalSize {
+		return nil, nil, ErrProducerFull
+	}
+
+	offset := producerPos & p.mask
+	hdr := (*uint32)(unsafe.Pointer(&p.data[offset]))
+	pad := (*uint32)(unsafe.Pointer(&p.data[offset+4]))
+	atomic.StoreUint32(hdr, size|userRingbufBusyBit)
+	*pad = 0
+
+	atomic.StoreUintptr(p.producerPos, producerPos+totalSize)
+
+	// The data pages are mapped twice, the record can be written past the
+	// end of the first mapping.
+	start := offset + userRingbufHdrSize
+	return hdr, p.data[start : start+uintptr(size)], nil
+}
+
+// commitUserRingbufRecord clears the busy bit of a reserved record, making
+// it available to the bpf program.
+func commitUserRingbufRecord(hdr *uint32, discard bool) {
+	newLen := atomic.LoadUint32(hdr) &^ userRingbufBusyBit
+	if discard {
+		newLen |= userRingbufDiscardBit
+	}
+	atomic.SwapUint32(hdr, newLen)
+}
+
+// closeOnSignal closes the Producer when signal is closed. It returns early
+// if the Producer is closed by other means.
+func (p *bpfProducer[T]) closeOnSignal(signal <-chan struct{}) {
+	select {
+	case <-signal:
+		_ = p.stop()
+	case <-p.terminatedCh:
+	}
+}
+
+// stop unmaps the user ring buffer and closes p.terminatedCh.
+func (p *bpfProducer[T]) stop() error {
+	p.stopOnce.Do(func() {
+		p.mu.Lock()
+		p.closed = true
+		errs := []error{unix.Munmap(p.producerMem), unix.Munmap(p.consumerMem)}
+		p.mu.Unlock()
+
+		if err := flaterrors.Join(errs...); err != nil {
+			p.stopErr = flaterrors.Join(err, ErrClosingProducer)
+		}
+
+		close(p.terminatedCh)
+	})
+
+	return p.stopErr
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+)
+
+// testProducerRecord is encoded in 8 bytes, i.e. 16 bytes with its header
+// in the user ring buffer.
+type testProducerRecord struct {
+	V uint64
+}
+
+func TestProducerPublish(t *testing.T) {
+	m := newTestUserRingbuf(t)
+	n := os.Getpagesize() / 16
+
+	p, err := NewProducer[testProducerRecord](m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+
+	for i := range n {
+		if err := p.Publish(testProducerRecord{V: uint64(i)}); err != nil {
+			t.Fatalf("publish %d: %v", i, err)
+		}
+	}
+
+	if err := p.Publish(testProducerRecord{}); !errors.Is(err, ErrProducerFull) {
+		t.Fatalf("want ErrProducerFull; got %v", err)
+	}
+
+	assertUserRingbufRecords(t, p.(*bpfProducer[testProducerRecord]), n, 0)
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Publish(testProducerRecord{}); !errors.Is(err, ErrProducerClosed) {
+		t.Fatalf("want ErrProducerClosed; got %v", err)
+	}
+}
+
+func TestProducerPublishBatch(t *testing.T) {
+	n := os.Getpagesize() / 16
+
+	for _, tc := range []struct {
+		name          string
+		published     int
+		batchSize     int
+		wantErr       error
+		wantCommitted int
+		wantDiscarded int
+	}{
+		{
+			name:          "batch fits",
+			published:     0,
+			batchSize:     10,
+			wantCommitted: 10,
+		},
+		{
+			name:          "batch fits exactly",
+			published:     n - 10,
+			batchSize:     10,
+			wantCommitted: n,
+		},
+		{
+			// -- the records reserved before the ring buffer is full are
+			//    discarded.
+			name:          "batch does not fit",
+			published:     n - 5,
+			batchSize:     10,
+			wantErr:       ErrProducerFull,
+			wantCommitted: n - 5,
+			wantDiscarded: 5,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := newProducer[testProducerRecord](newTestUserRingbuf(t), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { _ = p.Close() })
+
+			for range tc.published {
+				if err := p.Publish(testProducerRecord{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = p.PublishBatch(make([]testProducerRecord, tc.batchSize))
+			if tc.wantErr == nil && err != nil {
+				t.Fatal(err)
+			} else if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want %v; got %v", tc.wantErr, err)
+			}
+
+			assertUserRingbufRecords(t, p, tc.wantCommitted, tc.wantDiscarded)
+		})
+	}
+}
+
+func TestProducerPublishBatchEncodeFailure(t *testing.T) {
+	// -- NewProducer rejects types that cannot be encoded, hence the
+	//    Producer is created with newProducer.
+	p, err := newProducer[any](newTestUserRingbuf(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+
+	if err := p.PublishBatch([]any{uint64(1), "variable size"}); !errors.Is(err, ErrPublishing) {
+		t.Fatalf("want ErrPublishing; got %v", err)
+	}
+
+	assertUserRingbufRecords(t, p, 0, 0)
+}
+
+// newTestUserRingbuf creates a user ring buffer of one page.
+func newTestUserRingbuf(tb testing.TB) *ebpf.Map {
+	tb.Helper()
+
+	return newTestMap(tb, &ebpf.MapSpec{
+		Type:       ebpf.UserRingbuf,
+		MaxEntries: uint32(os.Getpagesize()),
+	})
+}
+
+// assertUserRingbufRecords walks the records written by p that have not
+// been consumed by a bpf program.
+func assertUserRingbufRecords[T any](tb testing.TB, p *bpfProducer[T], wantCommitted, wantDiscarded int) {
+	tb.Helper()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var committed, discarded int
+	pos := atomic.LoadUintptr(p.consumerPos)
+	end := atomic.LoadUintptr(p.producerPos)
+	for pos < end {
+		hdr := atomic.LoadUint32((*uint32)(unsafe.Pointer(&p.data[pos&p.mask])))
+		if hdr&userRingbufBusyBit != 0 {
+			tb.Fatalf("record at %d is still reserved", pos)
+		}
+
+		if hdr&userRingbufDiscardBit != 0 {
+			discarded++
+		} else {
+			committed++
+		}
+
+		size := hdr &^ (userRingbufBusyBit | userRingbufDiscardBit)
+		pos += (uintptr(size) + userRingbufHdrSize + 7) / 8 * 8
+	}
+
+	if committed != wantCommitted || discarded != wantDiscarded {
+		tb.Fatalf("want %d committed & %d discarded records; got %d & %d",
+			wantCommitted, wantDiscarded, committed, discarded)
+	}
+}
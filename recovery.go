@@ -0,0 +1,74 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"errors"
+
+	"github.com/alexandremahdhaoui/tooling/pkg/flaterrors"
+)
+
+var (
+	ErrRecoveringState       = errors.New("recovering state from the kernel")
+	ErrInvalidActivePointer  = errors.New("active pointer must be either 0 or 1")
+	ErrInvalidRecoveredState = errors.New("recovered state is invalid")
+)
+
+// -------------------------------------------------------------------
+// -- STATE RECOVERY
+// -------------------------------------------------------------------
+
+// WithStateRecovery rebuilds the internal state of a double-buffered data
+// structure from the kernel at construction time, instead of assuming both
+// internal maps are empty and `a` is active.
+//
+// It must be used when the bpf objects have been populated by a previous
+// process, e.g. when an agent restarts against pinned maps. It ensures the
+// first update writes to the correct passive side and deletes the correct
+// stale entries.
+//
+// The following are read from the kernel:
+//   - the "activePointer" bpf variable.
+//   - the `aLen` & `bLen` bpf variables.
+//   - the keys of the `a` & `b` maps.
+func WithStateRecovery() Option {
+	return func(o *options) {
+		o.stateRecovery = true
+	}
+}
+
+// recoverActivePointer reads the value of the "activePointer" bpf variable.
+func recoverActivePointer(activePointer variable) (uint8, error) {
+	var v uint8
+	if err := activePointer.Get(&v); err != nil {
+		return 0, flaterrors.Join(err, ErrRecoveringState)
+	}
+
+	if v > 1 {
+		return 0, flaterrors.Join(ErrInvalidActivePointer, ErrRecoveringState)
+	}
+
+	return v, nil
+}
+
+// recoverLen reads the value of a length bpf variable.
+func recoverLen(length variable) (uint32, error) {
+	var v uint32
+	if err := length.Get(&v); err != nil {
+		return 0, flaterrors.Join(err, ErrRecoveringState)
+	}
+	return v, nil
+}
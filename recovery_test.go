@@ -0,0 +1,212 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf"
+)
+
+func TestArrayRecoverState(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		activePointer uint8
+		aLen, bLen    uint32
+		cache         bool
+		wantErr       error
+		wantActive    []uint32
+		wantPassive   []uint32
+	}{
+		{
+			name:          "a is active",
+			activePointer: 0,
+			aLen:          2,
+			bLen:          1,
+			wantActive:    []uint32{1, 2},
+			wantPassive:   []uint32{3},
+		},
+		{
+			name:          "b is active",
+			activePointer: 1,
+			aLen:          2,
+			bLen:          1,
+			wantActive:    []uint32{3},
+			wantPassive:   []uint32{1, 2},
+		},
+		{
+			name:          "b is active with cache",
+			activePointer: 1,
+			aLen:          2,
+			bLen:          1,
+			cache:         true,
+			wantActive:    []uint32{3},
+			wantPassive:   []uint32{1, 2},
+		},
+		{
+			name:          "invalid active pointer",
+			activePointer: 2,
+			aLen:          2,
+			bLen:          1,
+			wantErr:       ErrInvalidActivePointer,
+		},
+		{
+			name:          "length exceeds max entries",
+			activePointer: 0,
+			aLen:          65,
+			bLen:          1,
+			wantErr:       ErrInvalidRecoveredState,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a, b := newTestMapSides(t, ebpf.Array, 4, 4, 64)
+			putTestEntries(t, a, map[uint32]uint32{0: 1, 1: 2})
+			putTestEntries(t, b, map[uint32]uint32{0: 3})
+
+			aLen, bLen, activePointer := newTestRecoveredVariables(t, tc.aLen, tc.bLen, tc.activePointer)
+
+			opts := []Option{WithStateRecovery()}
+			if tc.cache {
+				opts = append(opts, WithCache())
+			}
+
+			arr, err := newArray[uint32](a, b, aLen, bLen, activePointer, nil, opts...)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("want %v; got %v", tc.wantErr, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			defer arr.Close()
+
+			assertArray(t, arr.(*bpfArray[uint32]), tc.wantActive, tc.wantPassive)
+		})
+	}
+}
+
+func TestMapRecoverState(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		activePointer uint8
+		aLen, bLen    uint32
+		wantErr       error
+		wantActive    map[uint32]uint32
+		wantALen      uint32
+		wantBLen      uint32
+	}{
+		{
+			name:          "a is active",
+			activePointer: 0,
+			aLen:          2,
+			bLen:          1,
+			wantActive:    map[uint32]uint32{1: 10, 2: 20},
+			wantALen:      2,
+			wantBLen:      1,
+		},
+		{
+			name:          "b is active",
+			activePointer: 1,
+			aLen:          2,
+			bLen:          1,
+			wantActive:    map[uint32]uint32{3: 30},
+			wantALen:      2,
+			wantBLen:      1,
+		},
+		{
+			// -- the lengths are derived from the keys of each map.
+			name:          "length variables disagree",
+			activePointer: 0,
+			aLen:          5,
+			bLen:          0,
+			wantActive:    map[uint32]uint32{1: 10, 2: 20},
+			wantALen:      2,
+			wantBLen:      1,
+		},
+		{
+			name:          "invalid active pointer",
+			activePointer: 2,
+			wantErr:       ErrInvalidActivePointer,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a, b := newTestMapSides(t, ebpf.Hash, 4, 4, 64)
+			putTestEntries(t, a, map[uint32]uint32{1: 10, 2: 20})
+			putTestEntries(t, b, map[uint32]uint32{3: 30})
+
+			aLen, bLen, activePointer := newTestRecoveredVariables(t, tc.aLen, tc.bLen, tc.activePointer)
+
+			m, err := newMap[uint32, uint32](a, b, aLen, bLen, activePointer, nil, WithStateRecovery())
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("want %v; got %v", tc.wantErr, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			assertMapEntries(t, m.(*bpfMap[uint32, uint32]), tc.wantActive)
+
+			for _, v := range []struct {
+				name   string
+				length variable
+				want   uint32
+			}{
+				{name: "aLen", length: aLen, want: tc.wantALen},
+				{name: "bLen", length: bLen, want: tc.wantBLen},
+			} {
+				var got uint32
+				if err := v.length.Get(&got); err != nil {
+					t.Fatal(err)
+				}
+
+				if got != v.want {
+					t.Fatalf("want %s %d; got %d", v.name, v.want, got)
+				}
+			}
+		})
+	}
+}
+
+// newTestRecoveredVariables creates the aLen, bLen & "activePointer"
+// variables as written by a previous process.
+func newTestRecoveredVariables(tb testing.TB, aLen, bLen uint32, activePointer uint8) (variable, variable, variable) {
+	tb.Helper()
+
+	vars := []variable{newTestVariable(tb, 4), newTestVariable(tb, 4), newTestVariable(tb, 1)}
+	for i, v := range []any{aLen, bLen, activePointer} {
+		if err := vars[i].Set(v); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	return vars[0], vars[1], vars[2]
+}
+
+// putTestEntries writes entries to m.
+func putTestEntries(tb testing.TB, m *ebpf.Map, entries map[uint32]uint32) {
+	tb.Helper()
+
+	for k, v := range entries {
+		if err := m.Put(k, v); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/alexandremahdhaoui/tooling/pkg/flaterrors"
+)
+
+var (
+	ErrCommittingSwitchover = errors.New("committing switchover")
+	ErrSwitchoverClosed     = errors.New("switchover has already been committed or aborted")
+)
+
+// -------------------------------------------------------------------
+// -- RETRY POLICY
+// -------------------------------------------------------------------
+
+// RetryPolicy configures how a deferred switchover is retried when writing
+// the "activePointer" bpf variable fails.
+type RetryPolicy struct {
+	// MaxTries is the maximum number of attempts. Values lower than 1 are
+	// treated as 1.
+	MaxTries int
+	// Backoff returns the duration to wait after the attempt number `try`
+	// failed. `try` starts at 0. A nil Backoff does not wait.
+	Backoff func(try int) time.Duration
+}
+
+// DefaultRetryPolicy tries 3 times with a linear backoff of 5ms.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxTries: 3,
+		Backoff:  LinearBackoff(5 * time.Millisecond),
+	}
+}
+
+// LinearBackoff waits `try * step` after the attempt number `try` failed.
+func LinearBackoff(step time.Duration) func(try int) time.Duration {
+	return func(try int) time.Duration {
+		return time.Duration(try) * step
+	}
+}
+
+// WithRetryPolicy overrides the DefaultRetryPolicy used by deferred
+// switchovers.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retryPolicy = policy
+	}
+}
+
+// retry calls f until it succeeds or policy.MaxTries attempts failed.
+func (policy RetryPolicy) retry(f func() error) error {
+	var err error
+	for try := range max(policy.MaxTries, 1) {
+		if err = f(); err == nil {
+			return nil
+		}
+		if policy.Backoff != nil {
+			time.Sleep(policy.Backoff(try))
+		}
+	}
+	return err
+}
+
+// -------------------------------------------------------------------
+// -- SWITCHOVER
+// -------------------------------------------------------------------
+
+// Switchover is a pending switchover of a bpf data structure whose passive
+// internal map has been updated.
+//
+// Until the Switchover is either committed or aborted, the data structure
+// rejects subsequent updates with ErrSwitchoverPending.
+type Switchover interface {
+	// Commit performs the switchover, retrying according to the RetryPolicy
+	// of the data structure.
+	//
+	// If Commit fails, the switchover remains pending: Commit can be called
+	// again or the Switchover can be aborted.
+	// Commit returns ErrSwitchoverClosed if the Switchover has already been
+	// committed or aborted.
+	Commit() error
+
+	// Abort discards the staged passive data: the switchover will never be
+	// performed and the bpf program keeps reading the active map.
+	//
+	// The staged entries are left in the passive map, which is never read by
+	// the bpf program, and are overwritten by the next update.
+	// Abort is a no-op if the Switchover has already been committed or
+	// aborted.
+	Abort()
+}
+
+func newSwitchover(member switchoverMember, policy RetryPolicy) Switchover {
+	return &switchover{
+		member:      member,
+		retryPolicy: policy,
+		mu:          &sync.Mutex{},
+		closed:      false,
+	}
+}
+
+type switchover struct {
+	member      switchoverMember
+	retryPolicy RetryPolicy
+
+	mu *sync.Mutex
+	// closed is true once the switchover has been committed or aborted.
+	closed bool
+}
+
+// Commit implements Switchover.
+func (s *switchover) Commit() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return flaterrors.Join(ErrSwitchoverClosed, ErrCommittingSwitchover)
+	}
+
+	s.member.mutex().Lock()
+	defer s.member.mutex().Unlock()
+
+	if err := s.retryPolicy.retry(s.member.switchover); err != nil {
+		return flaterrors.Join(err, ErrCommittingSwitchover)
+	}
+
+	s.member.setSwitchoverPending(false)
+	s.closed = true
+
+	return nil
+}
+
+// Abort implements Switchover.
+func (s *switchover) Abort() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	s.member.mutex().Lock()
+	defer s.member.mutex().Unlock()
+
+	s.member.setSwitchoverPending(false)
+	s.closed = true
+}
@@ -0,0 +1,305 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"unsafe"
+
+	"github.com/alexandremahdhaoui/tooling/pkg/flaterrors"
+)
+
+var (
+	ErrCreatingNewSwitchoverGroup    = errors.New("creating new switchover group")
+	ErrCommittingSwitchoverGroup     = errors.New("committing switchover group")
+	ErrSwitchoverGroupMustNotBeEmpty = errors.New("switchover group must not be empty")
+	ErrNotSwitchoverGroupMember      = errors.New("data structure is not a member of the switchover group")
+	ErrActivePointerMismatch         = errors.New("members disagree about the value of the active pointer")
+	ErrActivePointerNotShared        = errors.New("members must share the same active pointer")
+	ErrInvalidStage                  = errors.New("invalid stage")
+)
+
+// -------------------------------------------------------------------
+// -- SWITCHOVER GROUP
+// -------------------------------------------------------------------
+
+// SwitchoverGroup coordinates the switchover of multiple bpf data
+// structures sharing the same "activePointer" bpf variable.
+//
+// Instead of juggling the functions returned by SetAndDeferSwitchover, users
+// commit a set of Stage. A commit:
+//   - stages all values in the passive side of their data structure.
+//   - restages members without a Stage with their current active values,
+//     hence the switchover is transparent for them.
+//   - performs exactly one write to the shared "activePointer".
+//   - flips the internal state of all members together.
+//
+// If any staging step fails, the commit is aborted before the switchover:
+// the bpf program keeps reading the active side of all members.
+//
+// Notes:
+//   - SwitchoverGroup is thread-safe.
+//   - Members are locked for the whole duration of a commit.
+type SwitchoverGroup interface {
+	// Commit stages all stages then performs the switchover of all members.
+	Commit(stages ...Stage) error
+}
+
+// Stage is a pending update of the passive side of a member of a
+// SwitchoverGroup. Please use StageArray, StageMap or StageVariable to
+// create one.
+//
+// A Stage created from an unsupported data structure carries an
+// ErrInvalidStage error, returned by Commit.
+type Stage struct {
+	member switchoverMember
+	set    func() error
+	err    error
+}
+
+// newInvalidStage returns a Stage failing the commit because ds was not
+// created by the expected constructor.
+func newInvalidStage(ds any, constructor string) Stage {
+	return Stage{err: flaterrors.Join(
+		fmt.Errorf("%T must be created by %s", ds, constructor),
+		ErrInvalidStage,
+	)}
+}
+
+// StageArray returns a Stage setting values in the passive side of arr.
+//
+// arr must have been created by NewArray.
+func StageArray[T any](arr Array[T], values []T) Stage {
+	member, ok := arr.(*bpfArray[T])
+	if !ok {
+		return newInvalidStage(arr, "NewArray")
+	}
+	return Stage{member: member, set: func() error { return member.set(values) }}
+}
+
+// StageMap returns a Stage setting newMap in the passive side of m.
+//
+// m must have been created by NewMap.
+func StageMap[K comparable, V any](m Map[K, V], newMap map[K]V) Stage {
+	member, ok := m.(*bpfMap[K, V])
+	if !ok {
+		return newInvalidStage(m, "NewMap")
+	}
+	return Stage{member: member, set: func() error { return member.set(newMap) }}
+}
+
+// StageVariable returns a Stage setting v in the passive side of dbv.
+//
+// dbv must have been created by NewDoubleBufferedVariable.
+func StageVariable[T any](dbv DoubleBufferedVariable[T], v T) Stage {
+	member, ok := dbv.(*bpfDoubleBufferedVariable[T])
+	if !ok {
+		return newInvalidStage(dbv, "NewDoubleBufferedVariable")
+	}
+	return Stage{member: member, set: func() error { return member.set(v) }}
+}
+
+// NewSwitchoverGroup returns a SwitchoverGroup coordinating members.
+//
+// members must be Array[T], Map[K,V] or DoubleBufferedVariable[T] created
+// by NewArray, NewMap or NewDoubleBufferedVariable that share the same
+// "activePointer" bpf variable, i.e. the same *ebpf.Variable was passed to
+// their constructors or they were loaded from the same pinned data section.
+//
+// NewSwitchoverGroup returns ErrActivePointerNotShared if members do not
+// share the same "activePointer", and ErrActivePointerMismatch if members
+// disagree about its current value.
+func NewSwitchoverGroup(members ...any) (SwitchoverGroup, error) {
+	if len(members) == 0 {
+		return nil, flaterrors.Join(ErrSwitchoverGroupMustNotBeEmpty, ErrCreatingNewSwitchoverGroup)
+	}
+
+	g := &switchoverGroup{
+		members: make([]switchoverMember, 0, len(members)),
+		mu:      &sync.Mutex{},
+	}
+
+	for _, m := range members {
+		member, ok := m.(switchoverMember)
+		if !ok {
+			return nil, flaterrors.Join(ErrNotSwitchoverGroupMember, ErrCreatingNewSwitchoverGroup)
+		}
+
+		if g.isMember(member) { // ignore duplicates.
+			continue
+		}
+		g.members = append(g.members, member)
+	}
+
+	// -- lock members in a global order, i.e. by address of their mutex, so
+	//    that groups sharing members cannot deadlock.
+	slices.SortFunc(g.members, func(a, b switchoverMember) int {
+		return cmp.Compare(uintptr(unsafe.Pointer(a.mutex())), uintptr(unsafe.Pointer(b.mutex())))
+	})
+
+	g.activePointer = g.members[0].getActivePointer()
+	for _, member := range g.members {
+		if !sameVariable(member.getActivePointer(), g.activePointer) {
+			return nil, flaterrors.Join(ErrActivePointerNotShared, ErrCreatingNewSwitchoverGroup)
+		}
+	}
+
+	g.lockMembers()
+	defer g.unlockMembers()
+
+	// -- check members agree about the value of the active pointer, both
+	//    from userspace and kernel point of view.
+	var want uint8
+	if err := g.activePointer.Get(&want); err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewSwitchoverGroup)
+	}
+
+	for _, member := range g.members {
+		if member.getActivePointerCache() != want {
+			return nil, flaterrors.Join(ErrActivePointerMismatch, ErrCreatingNewSwitchoverGroup)
+		}
+	}
+
+	return g, nil
+}
+
+type switchoverGroup struct {
+	// members are sorted by address of their mutex.
+	members []switchoverMember
+
+	// activePointer is the "activePointer" bpf variable shared by all
+	// members.
+	activePointer variable
+
+	// mu ensures only one commit is performed at a time.
+	mu *sync.Mutex
+}
+
+// Commit implements SwitchoverGroup.
+func (g *switchoverGroup) Commit(stages ...Stage) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.lockMembers()
+	defer g.unlockMembers()
+
+	staged := make(map[switchoverMember]struct{}, len(stages))
+	for _, stage := range stages {
+		if stage.err != nil {
+			return flaterrors.Join(stage.err, ErrCommittingSwitchoverGroup)
+		}
+
+		if !g.isMember(stage.member) {
+			return flaterrors.Join(ErrNotSwitchoverGroupMember, ErrCommittingSwitchoverGroup)
+		}
+		staged[stage.member] = struct{}{}
+	}
+
+	current := g.members[0].getActivePointerCache()
+	for _, member := range g.members {
+		if member.isSwitchoverPending() {
+			return flaterrors.Join(ErrSwitchoverPending, ErrCommittingSwitchoverGroup)
+		}
+
+		if member.getActivePointerCache() != current {
+			return flaterrors.Join(ErrActivePointerMismatch, ErrCommittingSwitchoverGroup)
+		}
+	}
+
+	// -- stage all members.
+	for _, stage := range stages {
+		if err := stage.set(); err != nil {
+			return flaterrors.Join(err, ErrCommittingSwitchoverGroup)
+		}
+	}
+
+	for _, member := range g.members {
+		if _, ok := staged[member]; ok {
+			continue
+		}
+
+		if err := member.restage(); err != nil {
+			return flaterrors.Join(err, ErrCommittingSwitchoverGroup)
+		}
+	}
+
+	// -- switchover
+	newActive := 1 - current
+	if err := g.activePointer.Set(newActive); err != nil {
+		return flaterrors.Join(err, ErrCommittingSwitchoverGroup)
+	}
+
+	for _, member := range g.members {
+		member.setActivePointerCache(newActive)
+	}
+
+	return nil
+}
+
+func (g *switchoverGroup) isMember(member switchoverMember) bool {
+	if member == nil {
+		return false
+	}
+	for _, m := range g.members {
+		if m == member {
+			return true
+		}
+	}
+	return false
+}
+
+// lockMembers locks all members in the global lock order.
+func (g *switchoverGroup) lockMembers() {
+	for _, member := range g.members {
+		member.mutex().Lock()
+	}
+}
+
+// unlockMembers unlocks all members in the reverse lock order.
+func (g *switchoverGroup) unlockMembers() {
+	for i := len(g.members) - 1; i >= 0; i-- {
+		g.members[i].mutex().Unlock()
+	}
+}
+
+// -------------------------------------------------------------------
+// -- SWITCHOVER MEMBER
+// -------------------------------------------------------------------
+
+// switchoverMember is implemented by bpf data structures that can be
+// coordinated by a SwitchoverGroup.
+//
+// Except mutex(), all methods must be called while holding the mutex.
+type switchoverMember interface {
+	// mutex returns the mutex protecting the internal state of the member.
+	mutex() *sync.RWMutex
+
+	getActivePointer() variable
+	getActivePointerCache() uint8
+	setActivePointerCache(v uint8)
+	isSwitchoverPending() bool
+	setSwitchoverPending(v bool)
+
+	// switchover writes the "activePointer" & flips the internal state.
+	switchover() error
+
+	// restage sets the passive side of the member to its active values.
+	restage() error
+}
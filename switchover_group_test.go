@@ -0,0 +1,140 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/cilium/ebpf"
+)
+
+func TestNewSwitchoverGroupActivePointerNotShared(t *testing.T) {
+	arr := newTestBPFArray(t, nil, nil)
+	m := newTestBPFMap(t, nil, nil)
+
+	_, err := NewSwitchoverGroup(arr, m)
+	if !errors.Is(err, ErrActivePointerNotShared) {
+		t.Fatalf("want ErrActivePointerNotShared; got %v", err)
+	}
+}
+
+func TestSwitchoverGroupCommitInvalidStage(t *testing.T) {
+	arr := newTestBPFArray(t, nil, nil)
+
+	g, err := NewSwitchoverGroup(arr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// notBPFArray is an Array[T] not created by NewArray.
+	type notBPFArray struct{ Array[uint32] }
+
+	err = g.Commit(StageArray[uint32](notBPFArray{}, []uint32{1}))
+	if !errors.Is(err, ErrInvalidStage) {
+		t.Fatalf("want ErrInvalidStage; got %v", err)
+	}
+}
+
+func TestSwitchoverGroupsSharingMembers(t *testing.T) {
+	activePointer := newTestVariable(t, 1)
+	arr := newTestBPFArray(t, activePointer, nil)
+	m := newTestBPFMap(t, activePointer, nil)
+
+	// -- both groups must lock members in the same order.
+	g0, err := NewSwitchoverGroup(arr, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g1, err := NewSwitchoverGroup(m, arr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i, g := range []SwitchoverGroup{g0, g1} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				v := uint32(i*100 + j)
+				if err := g.Commit(
+					StageArray[uint32](arr, []uint32{v}),
+					StageMap[uint32, uint32](m, map[uint32]uint32{v: v}),
+				); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// -- members agree about the committed values.
+	values, err := arr.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertMapEntries(t, m, map[uint32]uint32{values[0]: values[0]})
+	assertMapSidesConsistent(t, m)
+}
+
+func TestSwitchoverGroupPinnedMembers(t *testing.T) {
+	dir := newTestPinDir(t)
+	dataSection := newTestDataSection(t, newTestControlVariables("arr", "m")...)
+
+	arrA, arrB := newTestMapSides(t, ebpf.Array, 4, 4, 64)
+	if err := Pin(dir, "arr", arrA, arrB, dataSection); err != nil {
+		t.Fatal(err)
+	}
+
+	mA, mB := newTestMapSides(t, ebpf.Hash, 4, 4, 64)
+	if err := Pin(dir, "m", mA, mB, dataSection); err != nil {
+		t.Fatal(err)
+	}
+
+	arr, err := NewArrayFromPinPath[uint32](dir, "arr", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arr.Close()
+
+	m, err := NewMapFromPinPath[uint32, uint32](dir, "m", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	// -- both structures loaded their own handle to the pinned
+	//    "activePointer".
+	g, err := NewSwitchoverGroup(arr, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.Commit(
+		StageArray(arr, []uint32{1, 2}),
+		StageMap(m, map[uint32]uint32{3: 4}),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	assertArray(t, arr.(*bpfArray[uint32]), []uint32{1, 2}, []uint32{})
+	assertMapEntries(t, m.(*bpfMap[uint32, uint32]), map[uint32]uint32{3: 4})
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestSwitchover(t *testing.T) {
+	type op struct {
+		abort   bool
+		wantErr error
+	}
+
+	commit := func(wantErr error) op { return op{abort: false, wantErr: wantErr} }
+	abort := op{abort: true, wantErr: nil}
+
+	for _, tc := range []struct {
+		name     string
+		failures int
+		maxTries int
+		ops      []op
+
+		wantPending  bool
+		wantTries    int
+		wantSwitched bool
+	}{
+		{
+			name:         "commit",
+			maxTries:     1,
+			ops:          []op{commit(nil)},
+			wantPending:  false,
+			wantTries:    1,
+			wantSwitched: true,
+		},
+		{
+			name:         "commit retries",
+			failures:     2,
+			maxTries:     3,
+			ops:          []op{commit(nil)},
+			wantPending:  false,
+			wantTries:    3,
+			wantSwitched: true,
+		},
+		{
+			name:         "commit fails",
+			failures:     2,
+			maxTries:     2,
+			ops:          []op{commit(ErrCommittingSwitchover)},
+			wantPending:  true,
+			wantTries:    2,
+			wantSwitched: false,
+		},
+		{
+			name:         "commit again after a failure",
+			failures:     2,
+			maxTries:     2,
+			ops:          []op{commit(ErrCommittingSwitchover), commit(nil)},
+			wantPending:  false,
+			wantTries:    3,
+			wantSwitched: true,
+		},
+		{
+			name:         "abort after a failure",
+			failures:     2,
+			maxTries:     2,
+			ops:          []op{commit(ErrCommittingSwitchover), abort},
+			wantPending:  false,
+			wantTries:    2,
+			wantSwitched: false,
+		},
+		{
+			name:         "commit after commit",
+			maxTries:     1,
+			ops:          []op{commit(nil), commit(ErrSwitchoverClosed), abort},
+			wantPending:  false,
+			wantTries:    1,
+			wantSwitched: true,
+		},
+		{
+			name:         "abort",
+			maxTries:     1,
+			ops:          []op{abort, abort},
+			wantPending:  false,
+			wantTries:    0,
+			wantSwitched: false,
+		},
+		{
+			name:         "commit after abort",
+			maxTries:     1,
+			ops:          []op{abort, commit(ErrSwitchoverClosed)},
+			wantPending:  false,
+			wantTries:    0,
+			wantSwitched: false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			member := &fakeSwitchoverMember{
+				mu:       &sync.RWMutex{},
+				pending:  true,
+				failures: tc.failures,
+			}
+
+			sw := newSwitchover(member, RetryPolicy{MaxTries: tc.maxTries})
+
+			for i, op := range tc.ops {
+				if op.abort {
+					sw.Abort()
+					continue
+				}
+
+				err := sw.Commit()
+				if op.wantErr == nil && err != nil {
+					t.Fatalf("op %d: %v", i, err)
+				} else if !errors.Is(err, op.wantErr) {
+					t.Fatalf("op %d: want %v; got %v", i, op.wantErr, err)
+				}
+			}
+
+			if member.pending != tc.wantPending {
+				t.Fatalf("want pending %t; got %t", tc.wantPending, member.pending)
+			}
+
+			if member.tries != tc.wantTries {
+				t.Fatalf("want %d tries; got %d", tc.wantTries, member.tries)
+			}
+
+			if member.switched != tc.wantSwitched {
+				t.Fatalf("want switched %t; got %t", tc.wantSwitched, member.switched)
+			}
+		})
+	}
+}
+
+// fakeSwitchoverMember is a switchoverMember whose switchover fails the
+// first `failures` times.
+type fakeSwitchoverMember struct {
+	mu       *sync.RWMutex
+	pending  bool
+	failures int
+
+	tries    int
+	switched bool
+}
+
+func (m *fakeSwitchoverMember) mutex() *sync.RWMutex { return m.mu }
+
+func (m *fakeSwitchoverMember) getActivePointer() variable { return nil }
+
+func (m *fakeSwitchoverMember) getActivePointerCache() uint8 { return 0 }
+
+func (m *fakeSwitchoverMember) setActivePointerCache(uint8) {}
+
+func (m *fakeSwitchoverMember) isSwitchoverPending() bool { return m.pending }
+
+func (m *fakeSwitchoverMember) setSwitchoverPending(v bool) { m.pending = v }
+
+func (m *fakeSwitchoverMember) switchover() error {
+	m.tries++
+	if m.tries <= m.failures {
+		return errors.New("switchover failed")
+	}
+	m.switched = true
+	return nil
+}
+
+func (m *fakeSwitchoverMember) restage() error { return nil }
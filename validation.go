@@ -0,0 +1,329 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+)
+
+var ErrTypeMismatch = errors.New("go type does not match bpf type")
+
+// bpfObjNameLen is the maximum length of a bpf object name, including the
+// trailing NUL character.
+const bpfObjNameLen = 16
+
+// -------------------------------------------------------------------
+// -- TYPE MISMATCH ERROR
+// -------------------------------------------------------------------
+
+// TypeMismatchError is returned by constructors when a Go type parameter
+// does not match the layout of the bpf object it is used with.
+//
+// errors.Is(err, ErrTypeMismatch) returns true for a *TypeMismatchError.
+type TypeMismatchError struct {
+	// Object describes the mismatching bpf object, e.g. "map a: value".
+	Object string
+	// GoType is the name of the mismatching Go type.
+	GoType string
+	// Reason explains the mismatch, e.g. "size: want 4 bytes; got 8 bytes".
+	Reason string
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("%s: %s: %s: %s", ErrTypeMismatch, e.Object, e.GoType, e.Reason)
+}
+
+func (e *TypeMismatchError) Is(target error) bool {
+	return target == ErrTypeMismatch
+}
+
+// -------------------------------------------------------------------
+// -- VALIDATION
+// -------------------------------------------------------------------
+
+// validateType validates the Go type T against the size and, if typ is not
+// nil, the BTF layout of a bpf object.
+//
+// Types whose size cannot be determined, e.g. interfaces or types implementing
+// encoding.BinaryMarshaler with a variable size, are not validated.
+func validateType[T any](object string, size uint32, typ btf.Type) error {
+	return validateGoType(object, reflect.TypeFor[T](), binary.Size(new(T)), size, typ)
+}
+
+// validateGoType is the non-generic counterpart of validateType, where
+// goSize is the binary size of goType.
+func validateGoType(object string, goType reflect.Type, goSize int, size uint32, typ btf.Type) error {
+	if goSize < 0 {
+		return nil
+	}
+
+	if uint32(goSize) != size {
+		return &TypeMismatchError{
+			Object: object,
+			GoType: goType.String(),
+			Reason: fmt.Sprintf("size: want %d bytes; got %d bytes", size, goSize),
+		}
+	}
+
+	if typ == nil {
+		return nil
+	}
+
+	if reason := compareBTFLayout(goType, typ); reason != "" {
+		return &TypeMismatchError{
+			Object: object,
+			GoType: goType.String(),
+			Reason: reason,
+		}
+	}
+
+	return nil
+}
+
+// validateUintVariable validates that v is an unsigned integer of size bytes,
+// e.g. a __u8 or a __u32.
+func validateUintVariable(object string, v *ebpf.Variable, size uint32) error {
+	var typ btf.Type
+	if v.Type() != nil {
+		typ = v.Type().Type
+	}
+
+	return validateUint(object, v.Size(), typ, size)
+}
+
+// validateUint validates that a bpf variable of gotSize bytes and of BTF type
+// typ is an unsigned integer of size bytes. typ may be nil if BTF is not
+// available.
+func validateUint(object string, gotSize uint64, typ btf.Type, size uint32) error {
+	goType := fmt.Sprintf("uint%d", size*8)
+
+	if gotSize != uint64(size) {
+		return &TypeMismatchError{
+			Object: object,
+			GoType: goType,
+			Reason: fmt.Sprintf("size: want %d bytes; got %d bytes", size, gotSize),
+		}
+	}
+
+	if typ == nil {
+		return nil
+	}
+
+	if i, ok := btf.As[*btf.Int](typ); !ok || i.Encoding == btf.Signed {
+		return &TypeMismatchError{
+			Object: object,
+			GoType: goType,
+			Reason: fmt.Sprintf("bpf type must be an unsigned integer; got %s", typ),
+		}
+	}
+
+	return nil
+}
+
+func validateArrayMaps[T any](a, b *ebpf.Map) error {
+	for _, m := range []struct {
+		name string
+		m    *ebpf.Map
+	}{{name: "a", m: a}, {name: "b", m: b}} {
+		_, value := mapBTFTypes(m.m)
+
+		if err := validateType[uint32](fmt.Sprintf("map %s: key", m.name), m.m.KeySize(), nil); err != nil {
+			return err
+		}
+
+		if err := validateType[T](fmt.Sprintf("map %s: value", m.name), m.m.ValueSize(), value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateMapMaps validates the key and value types of a Map[K,V]'s maps.
+func validateMapMaps[K comparable, V any](a, b *ebpf.Map) error {
+	for _, m := range []struct {
+		name string
+		m    *ebpf.Map
+	}{{name: "a", m: a}, {name: "b", m: b}} {
+		key, value := mapBTFTypes(m.m)
+
+		if err := validateType[K](fmt.Sprintf("map %s: key", m.name), m.m.KeySize(), key); err != nil {
+			return err
+		}
+
+		if err := validateType[V](fmt.Sprintf("map %s: value", m.name), m.m.ValueSize(), value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateFixedSize validates that T is a fixed-size type that can be decoded
+// by encoding/binary.
+func validateFixedSize[T any](object string) error {
+	if binary.Size(new(T)) < 0 {
+		return &TypeMismatchError{
+			Object: object,
+			GoType: reflect.TypeFor[T]().String(),
+			Reason: "type must have a fixed size",
+		}
+	}
+	return nil
+}
+
+// validateControlVariables validates that aLen & bLen are __u32 and that
+// activePointer is a __u8.
+func validateControlVariables(aLen, bLen, activePointer *ebpf.Variable) error {
+	if err := validateUintVariable("variable aLen", aLen, 4); err != nil {
+		return err
+	}
+
+	if err := validateUintVariable("variable bLen", bLen, 4); err != nil {
+		return err
+	}
+
+	return validateUintVariable("variable activePointer", activePointer, 1)
+}
+
+// mapBTFTypes returns the BTF key & value types of a BTF-defined map, i.e.
+// declared in the `.maps` section. It returns nil types if BTF is not
+// available.
+func mapBTFTypes(m *ebpf.Map) (key, value btf.Type) {
+	info, err := m.Info()
+	if err != nil || info.Name == "" {
+		return nil, nil
+	}
+
+	handle, err := m.Handle()
+	if err != nil {
+		return nil, nil
+	}
+	defer handle.Close()
+
+	spec, err := handle.Spec(nil)
+	if err != nil {
+		return nil, nil
+	}
+
+	var datasec *btf.Datasec
+	if err := spec.TypeByName(".maps", &datasec); err != nil {
+		return nil, nil
+	}
+
+	for _, secinfo := range datasec.Vars {
+		v, ok := secinfo.Type.(*btf.Var)
+		if !ok || !matchMapName(v.Name, info.Name) {
+			continue
+		}
+
+		def, ok := btf.As[*btf.Struct](v.Type)
+		if !ok {
+			continue
+		}
+
+		for _, member := range def.Members {
+			ptr, ok := btf.As[*btf.Pointer](member.Type)
+			if !ok {
+				continue
+			}
+
+			switch member.Name {
+			case "key":
+				key = ptr.Target
+			case "value":
+				value = ptr.Target
+			}
+		}
+
+		return key, value
+	}
+
+	return nil, nil
+}
+
+// matchMapName returns true if kernelName is the name of the map named name
+// in the bpf program. Kernel map names are truncated to 15 characters.
+func matchMapName(name, kernelName string) bool {
+	if len(kernelName) < bpfObjNameLen-1 {
+		return name == kernelName
+	}
+	return strings.HasPrefix(name, kernelName)
+}
+
+// compareBTFLayout compares the layout of goType with typ field-by-field, as
+// encoded by encoding/binary. It returns the reason of the mismatch or an
+// empty string.
+//
+// Blank fields of goType, e.g. `_ [3]byte`, are considered as padding.
+func compareBTFLayout(goType reflect.Type, typ btf.Type) string {
+	if goType.Kind() != reflect.Struct {
+		return ""
+	}
+
+	def, ok := btf.As[*btf.Struct](typ)
+	if !ok {
+		return fmt.Sprintf("bpf type must be a struct; got %s", typ)
+	}
+
+	type field struct {
+		name         string
+		offset, size int
+	}
+
+	fields := make([]field, 0, goType.NumField())
+	offset := 0
+	for i := range goType.NumField() {
+		f := goType.Field(i)
+		size := binary.Size(reflect.New(f.Type).Interface())
+		if f.Name != "_" {
+			fields = append(fields, field{name: f.Name, offset: offset, size: size})
+		}
+		offset += size
+	}
+
+	if len(fields) != len(def.Members) {
+		return fmt.Sprintf("number of fields: want %d; got %d", len(def.Members), len(fields))
+	}
+
+	for i, member := range def.Members {
+		if member.BitfieldSize > 0 {
+			continue
+		}
+
+		size, err := btf.Sizeof(member.Type)
+		if err != nil {
+			continue
+		}
+
+		f := fields[i]
+		if wantOffset := int(member.Offset.Bytes()); f.offset != wantOffset || f.size != size {
+			return fmt.Sprintf(
+				"field %s (%s): want offset %d & size %d; got offset %d & size %d",
+				f.name, member.Name, wantOffset, size, f.offset, f.size,
+			)
+		}
+	}
+
+	return ""
+}
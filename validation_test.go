@@ -0,0 +1,85 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cilium/ebpf/btf"
+)
+
+func TestValidateUintSize(t *testing.T) {
+	err := validateUint("variable aLen", 8, nil, 4)
+	if !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("want ErrTypeMismatch; got %v", err)
+	}
+
+	if want := "size: want 4 bytes; got 8 bytes"; !strings.Contains(err.Error(), want) {
+		t.Fatalf("want error containing %q; got %q", want, err)
+	}
+}
+
+func TestLookupDataSectionVariables(t *testing.T) {
+	u8 := &btf.Int{Name: "__u8", Size: 1}
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	s32 := &btf.Int{Name: "int", Size: 4, Encoding: btf.Signed}
+
+	n := DefaultNaming().resolve("test")
+
+	for _, tc := range []struct {
+		name                      string
+		aLen, bLen, activePointer btf.Type
+		wantErr                   error
+	}{
+		{
+			name:          "valid",
+			aLen:          u32,
+			bLen:          u32,
+			activePointer: u8,
+		},
+		{
+			name:          "activePointer is not a __u8",
+			aLen:          u32,
+			bLen:          u32,
+			activePointer: u32,
+			wantErr:       ErrTypeMismatch,
+		},
+		{
+			name:          "bLen is signed",
+			aLen:          u32,
+			bLen:          s32,
+			activePointer: u8,
+			wantErr:       ErrTypeMismatch,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newTestDataSection(t,
+				testDataSectionVar{name: n.aLen, typ: tc.aLen},
+				testDataSectionVar{name: n.bLen, typ: tc.bLen},
+				testDataSectionVar{name: n.activePointer, typ: tc.activePointer},
+			)
+
+			_, err := lookupDataSectionVariables(m, n)
+			if tc.wantErr == nil && err != nil {
+				t.Fatal(err)
+			} else if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want %v; got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
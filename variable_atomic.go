@@ -0,0 +1,323 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/alexandremahdhaoui/tooling/pkg/flaterrors"
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	ErrDataSectionNotMmapable = errors.New("data section must be created with BPF_F_MMAPABLE")
+	ErrAtomicsNotSupported    = errors.New("atomic operations are not supported by this variable")
+	ErrNotAnInteger           = errors.New("variable type is not an integer")
+	ErrUpdatingVariable       = errors.New("updating variable")
+)
+
+// -------------------------------------------------------------------
+// -- VARIABLE FROM DATA SECTION
+// -------------------------------------------------------------------
+
+// NewVariableFromDataSection creates a Variable[T] from the bpf variable
+// named name in dataSection, e.g. the `.bss` or `.data` map of a
+// collection.
+//
+// The data section is memory-mapped by this function, hence it must have
+// been created with BPF_F_MMAPABLE. Unlike NewVariable, the returned
+// Variable supports atomic operations, i.e. Update, CompareAndSwap & Add.
+// The mapping is released by Close().
+//
+// Generics constraints:
+//   - T must have a fixed size.
+//   - T must fit in an aligned 8-byte word to support atomic operations.
+//
+// doneCh is a channel used to notify the bpf data structures or bpf
+// program has been closed and they can no longer be used.
+func NewVariableFromDataSection[T any](
+	dataSection *ebpf.Map,
+	name string,
+	doneCh <-chan struct{},
+	opts ...Option,
+) (Variable[T], error) {
+	if dataSection == nil {
+		return nil, flaterrors.Join(ErrEBPFObjectsMustNotBeNil, ErrCreatingNewVariable)
+	}
+
+	if dataSection.Flags()&unix.BPF_F_MMAPABLE == 0 {
+		return nil, flaterrors.Join(ErrDataSectionNotMmapable, ErrCreatingNewVariable)
+	}
+
+	secinfos, err := dataSectionSecinfos(dataSection)
+	if err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewVariable)
+	}
+
+	secinfo, ok := secinfos[name]
+	if !ok {
+		return nil, flaterrors.Join(fmt.Errorf("%w: %s", ErrVariableNotFound, name), ErrCreatingNewVariable)
+	}
+
+	var typ btf.Type
+	if v, ok := secinfo.Type.(*btf.Var); ok {
+		typ = v.Type
+	}
+
+	if err := validateFixedSize[T]("variable " + name); err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewVariable)
+	}
+
+	if err := validateType[T]("variable "+name, secinfo.Size, typ); err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewVariable)
+	}
+
+	info, err := dataSection.Info()
+	if err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewVariable)
+	}
+
+	mapID, _ := info.ID()
+
+	mem, err := mmapDataSection(dataSection)
+	if err != nil {
+		return nil, flaterrors.Join(err, ErrCreatingNewVariable)
+	}
+
+	obj, err := mem.variable(mapID, int(secinfo.Offset), int(secinfo.Size))
+	if err != nil {
+		_ = mem.close()
+		return nil, flaterrors.Join(err, ErrCreatingNewVariable)
+	}
+
+	// The mapping is owned by the Variable.
+	o := newOptions(opts...)
+	closer := func() error {
+		if o.closer != nil {
+			return flaterrors.Join(mem.close(), o.closer())
+		}
+		return mem.close()
+	}
+
+	return newVariable[T](obj, typ, doneCh, append(opts, withCloser(closer))...), nil
+}
+
+// -------------------------------------------------------------------
+// -- ATOMIC OPERATIONS
+// -------------------------------------------------------------------
+
+// atomicVariable is a variable supporting atomic read-modify-write
+// operations.
+type atomicVariable interface {
+	variable
+
+	// modify atomically replaces the binary representation of the
+	// variable with next(cur). It does not modify the variable if next
+	// returns false, and reports whether the variable has been modified.
+	// next may be called many times and must not retain cur.
+	modify(next func(cur []byte) ([]byte, bool)) (bool, error)
+}
+
+// Update implements Variable.
+func (bv *bpfVariable[T]) Update(fn func(T) T) error {
+	_, _, err := bv.update(func(cur T) (T, bool) {
+		return fn(cur), true
+	})
+	return err
+}
+
+// CompareAndSwap implements Variable.
+func (bv *bpfVariable[T]) CompareAndSwap(old, newValue T) (bool, error) {
+	oldBuf, err := binary.Append(nil, binary.NativeEndian, old)
+	if err != nil {
+		return false, flaterrors.Join(err, ErrUpdatingVariable)
+	}
+
+	_, swapped, err := bv.update(func(cur T) (T, bool) {
+		curBuf, err := binary.Append(nil, binary.NativeEndian, cur)
+		return newValue, err == nil && bytes.Equal(curBuf, oldBuf)
+	})
+
+	return swapped, err
+}
+
+// Add implements Variable.
+func (bv *bpfVariable[T]) Add(delta T) (T, error) {
+	if !isInteger[T]() {
+		return *new(T), flaterrors.Join(ErrNotAnInteger, ErrUpdatingVariable)
+	}
+
+	v, _, err := bv.update(func(cur T) (T, bool) {
+		return addInteger(cur, delta), true
+	})
+
+	return v, err
+}
+
+// update atomically replaces the value of the variable with fn(cur),
+// unless fn returns false. It returns the new value and reports whether
+// the variable has been modified.
+func (bv *bpfVariable[T]) update(fn func(cur T) (T, bool)) (T, bool, error) {
+	bv.mu.Lock()
+	defer bv.mu.Unlock()
+
+	if bv.lc.closed() {
+		return *new(T), false, ErrDataStructureClosed
+	}
+
+	obj, ok := bv.obj.(atomicVariable)
+	if !ok {
+		return *new(T), false, flaterrors.Join(ErrAtomicsNotSupported, ErrUpdatingVariable)
+	}
+
+	var next T
+	var fnErr error
+	modified, err := obj.modify(func(cur []byte) ([]byte, bool) {
+		var v T
+		if _, fnErr = binary.Decode(cur, binary.NativeEndian, &v); fnErr != nil {
+			return nil, false
+		}
+
+		var ok bool
+		if next, ok = fn(v); !ok {
+			return nil, false
+		}
+
+		var buf []byte
+		if buf, fnErr = binary.Append(nil, binary.NativeEndian, next); fnErr != nil {
+			return nil, false
+		}
+
+		return buf, true
+	})
+	if err = flaterrors.Join(err, fnErr); err != nil {
+		return *new(T), false, flaterrors.Join(err, ErrUpdatingVariable)
+	}
+
+	if modified && bv.cache {
+		bv.valueCache, bv.cached = next, true
+	}
+
+	return next, modified, nil
+}
+
+// isInteger returns true if T is a fixed-size integer type.
+func isInteger[T any]() bool {
+	return isIntegerKind(reflect.TypeFor[T]().Kind())
+}
+
+// isIntegerKind returns true if kind is a fixed-size integer kind.
+func isIntegerKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+// addInteger returns v + delta, wrapping around on overflow. T must be an
+// integer type.
+func addInteger[T any](v, delta T) T {
+	rv, rdelta := reflect.ValueOf(&v).Elem(), reflect.ValueOf(delta)
+	if rv.CanInt() {
+		rv.SetInt(rv.Int() + rdelta.Int())
+	} else {
+		rv.SetUint(rv.Uint() + rdelta.Uint())
+	}
+	return v
+}
+
+// -------------------------------------------------------------------
+// -- MEMORY VARIABLE
+// -------------------------------------------------------------------
+
+var _ atomicVariable = &memoryVariable{}
+
+// modify implements atomicVariable. It performs a compare-and-swap loop on
+// the aligned 4-byte or 8-byte word containing the variable, hence
+// neighbouring variables sharing the word are left untouched.
+func (v *memoryVariable) modify(next func(cur []byte) ([]byte, bool)) (bool, error) {
+	start, width, ok := v.word()
+	if !ok {
+		return false, ErrAtomicsNotSupported
+	}
+
+	v.mem.mu.RLock()
+	defer v.mem.mu.RUnlock()
+
+	if v.mem.b == nil {
+		return false, ErrDataStructureClosed
+	}
+
+	ptr := unsafe.Pointer(&v.mem.b[start])
+	shift := v.offset - start
+	buf := make([]byte, width)
+
+	for {
+		var old uint64
+		if width == 4 {
+			old = uint64(atomic.LoadUint32((*uint32)(ptr)))
+			binary.NativeEndian.PutUint32(buf, uint32(old))
+		} else {
+			old = atomic.LoadUint64((*uint64)(ptr))
+			binary.NativeEndian.PutUint64(buf, old)
+		}
+
+		value, ok := next(buf[shift : shift+v.size])
+		if !ok {
+			return false, nil
+		}
+
+		if len(value) != v.size {
+			return false, ErrVariableSizeMismatch
+		}
+
+		copy(buf[shift:], value)
+
+		var swapped bool
+		if width == 4 {
+			swapped = atomic.CompareAndSwapUint32((*uint32)(ptr), uint32(old), binary.NativeEndian.Uint32(buf))
+		} else {
+			swapped = atomic.CompareAndSwapUint64((*uint64)(ptr), old, binary.NativeEndian.Uint64(buf))
+		}
+
+		if swapped {
+			return true, nil
+		}
+	}
+}
+
+// word returns the offset & the width of the smallest aligned word that
+// can be operated on atomically and contains the variable.
+func (v *memoryVariable) word() (int, int, bool) {
+	for _, width := range []int{4, 8} {
+		start := v.offset &^ (width - 1)
+		if v.offset+v.size <= start+width {
+			return start, width, true
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,226 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+)
+
+var (
+	testU8  = &btf.Int{Name: "__u8", Size: 1}
+	testU16 = &btf.Int{Name: "__u16", Size: 2}
+	testU32 = &btf.Int{Name: "__u32", Size: 4}
+)
+
+func TestVariableAddConcurrent(t *testing.T) {
+	const goroutines, adds = 8, 1000
+
+	// -- hits & misses share the same 4-byte word.
+	m := newTestDataSection(t,
+		testDataSectionVar{name: "counter", typ: testU32},
+		testDataSectionVar{name: "hits", typ: testU16},
+		testDataSectionVar{name: "misses", typ: testU8},
+	)
+
+	counter := newTestDataSectionVariable[uint32](t, m, "counter")
+	hits := newTestDataSectionVariable[uint16](t, m, "hits")
+	misses := newTestDataSectionVariable[uint8](t, m, "misses")
+
+	wg := &sync.WaitGroup{}
+	errCh := make(chan error, 3*goroutines)
+	for range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range adds {
+				_, err1 := counter.Add(1)
+				_, err2 := hits.Add(1)
+				_, err3 := misses.Add(1)
+				if err := errors.Join(err1, err2, err3); err != nil {
+					errCh <- err
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	for err := range errCh {
+		t.Fatal(err)
+	}
+
+	assertDataSectionVariable(t, counter, goroutines*adds)
+	assertDataSectionVariable(t, hits, goroutines*adds)
+	// -- misses wraps around on overflow.
+	assertDataSectionVariable(t, misses, uint8(goroutines*adds%256))
+}
+
+func TestVariableCompareAndSwap(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		old         uint32
+		wantSwapped bool
+		want        uint32
+	}{
+		{
+			name:        "current value matches old",
+			old:         1,
+			wantSwapped: true,
+			want:        2,
+		},
+		{
+			name:        "current value does not match old",
+			old:         3,
+			wantSwapped: false,
+			want:        1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newTestDataSection(t, testDataSectionVar{name: "v", typ: testU32})
+			v := newTestDataSectionVariable[uint32](t, m, "v")
+
+			if err := v.Set(1); err != nil {
+				t.Fatal(err)
+			}
+
+			swapped, err := v.CompareAndSwap(tc.old, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if swapped != tc.wantSwapped {
+				t.Fatalf("want swapped %t; got %t", tc.wantSwapped, swapped)
+			}
+
+			assertDataSectionVariable(t, v, tc.want)
+		})
+	}
+}
+
+func TestVariableAddPreservesNeighbours(t *testing.T) {
+	// -- before, v8 & v16 are laid out in the same 4-byte word.
+	vars := []testDataSectionVar{
+		{name: "before", typ: testU8},
+		{name: "v8", typ: testU8},
+		{name: "v16", typ: testU16},
+		{name: "after", typ: testU32},
+	}
+
+	t.Run("1-byte variable", func(t *testing.T) {
+		m := newTestDataSection(t, vars...)
+		want := fillDataSection(t, m, 0xa5)
+		want[1] = 0xa6
+
+		v := newTestDataSectionVariable[uint8](t, m, "v8")
+		if _, err := v.Add(1); err != nil {
+			t.Fatal(err)
+		}
+
+		assertDataSection(t, m, want)
+	})
+
+	t.Run("2-byte variable", func(t *testing.T) {
+		m := newTestDataSection(t, vars...)
+		want := fillDataSection(t, m, 0xa5)
+		binary.NativeEndian.PutUint16(want[2:], 0xa5a6)
+
+		v := newTestDataSectionVariable[uint16](t, m, "v16")
+		if _, err := v.Add(1); err != nil {
+			t.Fatal(err)
+		}
+
+		assertDataSection(t, m, want)
+	})
+}
+
+func TestVariableAtomicsNotSupported(t *testing.T) {
+	// -- failingVariable hides the atomic operations of the variable, like
+	//    *ebpf.Variable used by NewVariable.
+	bv := newTestBPFVariable(t, failingVariable{newTestVariable(t, 4)}, nil)
+
+	if err := bv.Update(func(v uint32) uint32 { return v + 1 }); !errors.Is(err, ErrAtomicsNotSupported) {
+		t.Fatalf("Update: want ErrAtomicsNotSupported; got %v", err)
+	}
+
+	if _, err := bv.CompareAndSwap(0, 1); !errors.Is(err, ErrAtomicsNotSupported) {
+		t.Fatalf("CompareAndSwap: want ErrAtomicsNotSupported; got %v", err)
+	}
+
+	if _, err := bv.Add(1); !errors.Is(err, ErrAtomicsNotSupported) {
+		t.Fatalf("Add: want ErrAtomicsNotSupported; got %v", err)
+	}
+}
+
+// newTestDataSectionVariable creates the Variable named name in
+// dataSection. It is closed at the end of the test.
+func newTestDataSectionVariable[T any](tb testing.TB, dataSection *ebpf.Map, name string) Variable[T] {
+	tb.Helper()
+
+	v, err := NewVariableFromDataSection[T](dataSection, name, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { _ = v.Close() })
+
+	return v
+}
+
+// fillDataSection sets all bytes of m to b and returns its value.
+func fillDataSection(tb testing.TB, m *ebpf.Map, b byte) []byte {
+	tb.Helper()
+
+	value := bytes.Repeat([]byte{b}, int(m.ValueSize()))
+	if err := m.Update(uint32(0), value, ebpf.UpdateAny); err != nil {
+		tb.Fatal(err)
+	}
+
+	return value
+}
+
+func assertDataSection(tb testing.TB, m *ebpf.Map, want []byte) {
+	tb.Helper()
+
+	got, err := m.LookupBytes(uint32(0))
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	if !bytes.Equal(got, want) {
+		tb.Fatalf("want %x; got %x", want, got)
+	}
+}
+
+func assertDataSectionVariable[T comparable](tb testing.TB, v Variable[T], want T) {
+	tb.Helper()
+
+	got, err := v.Get()
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	if got != want {
+		tb.Fatalf("want %v; got %v", want, got)
+	}
+}
@@ -0,0 +1,272 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/alexandremahdhaoui/tooling/pkg/flaterrors"
+	"github.com/cilium/ebpf/btf"
+)
+
+var (
+	ErrAccessingField          = errors.New("accessing variable field")
+	ErrFieldNotFound           = errors.New("field not found")
+	ErrVariableHasNoBTF        = errors.New("variable has no BTF type information")
+	ErrBitfieldNotSupported    = errors.New("bitfields are not supported")
+	ErrFieldWritesNotSupported = errors.New("field writes are not supported by this variable")
+)
+
+// -------------------------------------------------------------------
+// -- FIELD ACCESS
+// -------------------------------------------------------------------
+
+// GetField implements Variable.
+func (bv *bpfVariable[T]) GetField(path string, out any) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return flaterrors.Join(fmt.Errorf("out must be a non-nil pointer; got %T", out), ErrAccessingField)
+	}
+
+	f, err := bv.lookupField(path, rv.Elem().Type(), binary.Size(out))
+	if err != nil {
+		return flaterrors.Join(err, ErrAccessingField)
+	}
+
+	buf := make([]byte, binary.Size(new(T)))
+
+	bv.mu.RLock()
+	err = bv.obj.Get(buf)
+	bv.mu.RUnlock()
+
+	if err != nil {
+		return flaterrors.Join(err, ErrAccessingField)
+	}
+
+	if _, err := binary.Decode(buf[f.offset:f.offset+f.size], binary.NativeEndian, out); err != nil {
+		return flaterrors.Join(err, ErrAccessingField)
+	}
+
+	return nil
+}
+
+// SetField implements Variable.
+func (bv *bpfVariable[T]) SetField(path string, v any) error {
+	f, err := bv.lookupField(path, reflect.TypeOf(v), binary.Size(v))
+	if err != nil {
+		return flaterrors.Join(err, ErrAccessingField)
+	}
+
+	buf, err := binary.Append(nil, binary.NativeEndian, v)
+	if err != nil {
+		return flaterrors.Join(err, ErrAccessingField)
+	}
+
+	bv.mu.Lock()
+	defer bv.mu.Unlock()
+
+	if bv.lc.closed() {
+		return ErrDataStructureClosed
+	}
+
+	obj, ok := bv.obj.(fieldWriter)
+	if !ok {
+		return flaterrors.Join(ErrFieldWritesNotSupported, ErrAccessingField)
+	}
+
+	if err := obj.setAt(f.offset, buf); err != nil {
+		return flaterrors.Join(err, ErrAccessingField)
+	}
+
+	// The cached value is not patched: the other fields may have been
+	// modified by the bpf program.
+	bv.cached = false
+
+	return nil
+}
+
+// lookupField resolves the field at path in the BTF type of the variable
+// and validates goType against it.
+func (bv *bpfVariable[T]) lookupField(path string, goType reflect.Type, goSize int) (field, error) {
+	if bv.typ == nil {
+		return field{}, ErrVariableHasNoBTF
+	}
+
+	if err := validateFixedSize[T]("variable"); err != nil {
+		return field{}, err
+	}
+
+	f, err := lookupField(bv.typ, path)
+	if err != nil {
+		return field{}, err
+	}
+
+	if goType == nil {
+		return field{}, fmt.Errorf("field %s: value must not be nil", path)
+	}
+
+	object := "variable field " + path
+	if reason := compareBTFKind(goType, f.typ); reason != "" {
+		return field{}, &TypeMismatchError{Object: object, GoType: goType.String(), Reason: reason}
+	}
+
+	if err := validateGoType(object, goType, goSize, uint32(f.size), f.typ); err != nil {
+		return field{}, err
+	}
+
+	return f, nil
+}
+
+// field is a member of a struct-typed bpf variable.
+type field struct {
+	// offset is the offset in bytes of the field in the variable.
+	offset int
+	size   int
+	typ    btf.Type
+}
+
+// lookupField resolves path, a dot-separated list of member names such as
+// "limits.max_conns", in typ. Members of anonymous structs & unions are
+// looked up as if they were members of the enclosing type, like in C.
+func lookupField(typ btf.Type, path string) (field, error) {
+	f := field{offset: 0, size: 0, typ: typ}
+
+	for _, name := range strings.Split(path, ".") {
+		members, ok := compositeMembers(f.typ)
+		if !ok {
+			return field{}, fmt.Errorf("%w: %s: %s is not a struct or a union", ErrFieldNotFound, path, name)
+		}
+
+		member, offset, ok := findMember(members, name)
+		if !ok {
+			return field{}, fmt.Errorf("%w: %s: no member %q", ErrFieldNotFound, path, name)
+		}
+
+		if member.BitfieldSize > 0 {
+			return field{}, fmt.Errorf("%w: %s", ErrBitfieldNotSupported, path)
+		}
+
+		f.offset += int(offset)
+		f.typ = member.Type
+	}
+
+	size, err := btf.Sizeof(f.typ)
+	if err != nil {
+		return field{}, err
+	}
+	f.size = size
+
+	return f, nil
+}
+
+// findMember returns the member named name and its offset in bytes,
+// descending into anonymous members.
+func findMember(members []btf.Member, name string) (btf.Member, uint32, bool) {
+	for _, member := range members {
+		if member.Name == name {
+			return member, member.Offset.Bytes(), true
+		}
+
+		if member.Name != "" {
+			continue
+		}
+
+		if inner, ok := compositeMembers(member.Type); ok {
+			if m, offset, ok := findMember(inner, name); ok {
+				return m, member.Offset.Bytes() + offset, true
+			}
+		}
+	}
+
+	return btf.Member{}, 0, false
+}
+
+// compositeMembers returns the members of typ if it is a struct or a
+// union.
+func compositeMembers(typ btf.Type) ([]btf.Member, bool) {
+	switch t := btf.UnderlyingType(typ).(type) {
+	case *btf.Struct:
+		return t.Members, true
+	case *btf.Union:
+		return t.Members, true
+	default:
+		return nil, false
+	}
+}
+
+// compareBTFKind returns a non-empty reason if goType cannot represent the
+// BTF type typ, e.g. a float64 written to a __u64.
+func compareBTFKind(goType reflect.Type, typ btf.Type) string {
+	kind := goType.Kind()
+
+	var ok bool
+	switch btf.UnderlyingType(typ).(type) {
+	case *btf.Int:
+		ok = isIntegerKind(kind) || kind == reflect.Bool
+	case *btf.Enum, *btf.Pointer:
+		ok = isIntegerKind(kind)
+	case *btf.Float:
+		ok = kind == reflect.Float32 || kind == reflect.Float64
+	case *btf.Array:
+		ok = kind == reflect.Array
+	case *btf.Struct, *btf.Union:
+		ok = kind == reflect.Struct || kind == reflect.Array
+	default:
+		ok = true
+	}
+
+	if !ok {
+		return fmt.Sprintf("bpf type %s cannot be represented by a Go %s", typ, kind)
+	}
+
+	return ""
+}
+
+// -------------------------------------------------------------------
+// -- FIELD WRITER
+// -------------------------------------------------------------------
+
+// fieldWriter is a variable supporting writes of a subset of its bytes.
+type fieldWriter interface {
+	variable
+
+	// setAt writes in at offset in the variable, leaving the other bytes
+	// untouched.
+	setAt(offset int, in []byte) error
+}
+
+var _ fieldWriter = &memoryVariable{}
+
+// setAt implements fieldWriter.
+func (v *memoryVariable) setAt(offset int, in []byte) error {
+	if offset < 0 || offset+len(in) > v.size {
+		return ErrVariableSizeMismatch
+	}
+
+	v.mem.mu.RLock()
+	defer v.mem.mu.RUnlock()
+
+	if v.mem.b == nil {
+		return ErrDataStructureClosed
+	}
+
+	copy(v.mem.b[v.offset+offset:], in)
+	return nil
+}
@@ -0,0 +1,239 @@
+/*
+ * Copyright 2025 Alexandre Mahdhaoui
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package ebpfstruct
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/cilium/ebpf/btf"
+)
+
+// testConfig is the Go representation of testConfigType().
+type testConfig struct {
+	Enabled  uint8
+	_        [3]uint8
+	Flags    uint32
+	Limits   testLimits
+	Raw      uint32
+	_        [4]uint8
+	Deadline uint64
+}
+
+type testLimits struct {
+	MaxConns uint32
+	Min      uint16
+	Max      uint16
+}
+
+// testConfigType returns the BTF type of:
+//
+//	struct config {
+//		__u8 enabled;
+//		__u32 flags : 3;
+//		struct limits {
+//			__u32 max_conns;
+//			__u16 min;
+//			__u16 max;
+//		} limits;
+//		union {
+//			__u32 raw;
+//			__u8 bytes[4];
+//		};
+//		struct {
+//			__u64 deadline;
+//		};
+//	};
+func testConfigType() btf.Type {
+	u64 := &btf.Int{Name: "__u64", Size: 8}
+
+	limits := &btf.Struct{
+		Name: "limits",
+		Size: 8,
+		Members: []btf.Member{
+			{Name: "max_conns", Type: testU32, Offset: 0},
+			{Name: "min", Type: testU16, Offset: 32},
+			{Name: "max", Type: testU16, Offset: 48},
+		},
+	}
+
+	return &btf.Struct{
+		Name: "config",
+		Size: 32,
+		Members: []btf.Member{
+			{Name: "enabled", Type: testU8, Offset: 0},
+			{Name: "flags", Type: testU32, Offset: 32, BitfieldSize: 3},
+			{Name: "limits", Type: limits, Offset: 64},
+			{Type: &btf.Union{Size: 4, Members: []btf.Member{
+				{Name: "raw", Type: testU32, Offset: 0},
+				{Name: "bytes", Type: &btf.Array{Index: testU32, Type: testU8, Nelems: 4}, Offset: 0},
+			}}, Offset: 128},
+			{Type: &btf.Struct{Size: 8, Members: []btf.Member{
+				{Name: "deadline", Type: u64, Offset: 0},
+			}}, Offset: 192},
+		},
+	}
+}
+
+func TestVariableField(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		path string
+		v    any
+		want testConfig
+	}{
+		{
+			name: "dotted path",
+			path: "limits.max_conns",
+			v:    uint32(42),
+			want: testConfig{Enabled: 1, Limits: testLimits{MaxConns: 42, Min: 2, Max: 3}, Raw: 4, Deadline: 5},
+		},
+		{
+			name: "struct member",
+			path: "limits",
+			v:    testLimits{MaxConns: 6, Min: 7, Max: 8},
+			want: testConfig{Enabled: 1, Limits: testLimits{MaxConns: 6, Min: 7, Max: 8}, Raw: 4, Deadline: 5},
+		},
+		{
+			name: "member of an anonymous union",
+			path: "raw",
+			v:    uint32(42),
+			want: testConfig{Enabled: 1, Limits: testLimits{MaxConns: 1, Min: 2, Max: 3}, Raw: 42, Deadline: 5},
+		},
+		{
+			name: "member of an anonymous struct",
+			path: "deadline",
+			v:    uint64(42),
+			want: testConfig{Enabled: 1, Limits: testLimits{MaxConns: 1, Min: 2, Max: 3}, Raw: 4, Deadline: 42},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v := newTestConfigVariable(t)
+
+			if err := v.SetField(tc.path, tc.v); err != nil {
+				t.Fatal(err)
+			}
+
+			// -- the other fields are left untouched.
+			assertDataSectionVariable(t, v, tc.want)
+
+			out := reflect.New(reflect.TypeOf(tc.v))
+			if err := v.GetField(tc.path, out.Interface()); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := out.Elem().Interface(); got != tc.v {
+				t.Fatalf("GetField: want %v; got %v", tc.v, got)
+			}
+		})
+	}
+}
+
+func TestVariableFieldErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		path    string
+		v       any
+		wantErr error
+	}{
+		{
+			name:    "unknown field",
+			path:    "limits.unknown",
+			v:       uint32(0),
+			wantErr: ErrFieldNotFound,
+		},
+		{
+			name:    "descending into a scalar",
+			path:    "enabled.value",
+			v:       uint32(0),
+			wantErr: ErrFieldNotFound,
+		},
+		{
+			name:    "bitfield",
+			path:    "flags",
+			v:       uint32(0),
+			wantErr: ErrBitfieldNotSupported,
+		},
+		{
+			name:    "size mismatch",
+			path:    "limits.max_conns",
+			v:       uint64(0),
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name:    "kind mismatch",
+			path:    "limits.max_conns",
+			v:       float32(0),
+			wantErr: ErrTypeMismatch,
+		},
+		{
+			name:    "integer for an array",
+			path:    "bytes",
+			v:       uint32(0),
+			wantErr: ErrTypeMismatch,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v := newTestConfigVariable(t)
+
+			if err := v.SetField(tc.path, tc.v); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("SetField: want %v; got %v", tc.wantErr, err)
+			}
+
+			out := reflect.New(reflect.TypeOf(tc.v))
+			if err := v.GetField(tc.path, out.Interface()); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("GetField: want %v; got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestVariableSetFieldNotSupported(t *testing.T) {
+	// -- failingVariable hides the field writes of the variable, like
+	//    *ebpf.Variable used by NewVariable.
+	bv := newVariable[testConfig](failingVariable{newTestVariable(t, 32)}, testConfigType(), nil)
+	t.Cleanup(func() { _ = bv.Close() })
+
+	if err := bv.SetField("limits.max_conns", uint32(1)); !errors.Is(err, ErrFieldWritesNotSupported) {
+		t.Fatalf("want ErrFieldWritesNotSupported; got %v", err)
+	}
+
+	var maxConns uint32
+	if err := bv.GetField("limits.max_conns", &maxConns); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestConfigVariable creates a Variable[testConfig] initialized with
+// distinct non-zero fields.
+func newTestConfigVariable(tb testing.TB) Variable[testConfig] {
+	tb.Helper()
+
+	m := newTestDataSection(tb, testDataSectionVar{name: "config", typ: testConfigType()})
+	v := newTestDataSectionVariable[testConfig](tb, m, "config")
+
+	if err := v.Set(testConfig{
+		Enabled:  1,
+		Limits:   testLimits{MaxConns: 1, Min: 2, Max: 3},
+		Raw:      4,
+		Deadline: 5,
+	}); err != nil {
+		tb.Fatal(err)
+	}
+
+	return v
+}